@@ -1,18 +1,91 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/NGerasimovvv/GraphQL/graph"
 	"github.com/NGerasimovvv/GraphQL/internal/config"
+	"github.com/NGerasimovvv/GraphQL/internal/persisted"
 	"github.com/NGerasimovvv/GraphQL/internal/storage"
 	"github.com/NGerasimovvv/GraphQL/server"
 )
 
 func main() {
-	cfg := config.LoadConfig()
+	args := os.Args[1:]
+	if len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		cfg, err := config.LoadConfig(args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(args) >= 2 && args[0] == "operations" && args[1] == "extract" {
+		if err := extractOperations(args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.LoadConfig(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger := log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime)
+	logger.Printf("StorageType: %s", cfg.Storage.StorageType)
 	storageType := storage.StorageType(cfg)
 	defer func() {
 		if postgresStorage, ok := storageType.(*storage.PostgresStorage); ok {
 			postgresStorage.ClosePostgres()
 		}
 	}()
-	server.InitServer(storageType)
+	server.InitServer(cfg, storage.WithCache(cfg, storageType))
+}
+
+// extractOperations writes a persisted query manifest with the operations
+// found in the client code under the given paths.
+func extractOperations(args []string) error {
+	fs := flag.NewFlagSet("operations extract", flag.ContinueOnError)
+	out := fs.String("out", "", "manifest file to write, stdout if empty")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: operations extract [-out manifest.json] path...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no paths to extract operations from")
+	}
+
+	schema := graph.NewExecutableSchema(graph.Config{}).Schema()
+	manifest, err := persisted.ExtractFiles(schema, fs.Args())
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d operations to %s\n", len(manifest.Operations), *out)
+	return nil
 }
@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/requestid"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditPage struct {
+	AuditLog struct {
+		Entries []struct {
+			Actor      *string
+			Action     string
+			TargetType *string
+			TargetID   *string
+			RequestID  *string
+			Error      *string
+			Diff       []struct {
+				Field  string
+				Before *string
+				After  *string
+			}
+		}
+		EndCursor   *string
+		HasNextPage bool
+	}
+}
+
+const auditQuery = `query($filter: AuditFilter, $first: Int, $after: String) {
+	auditLog(filter: $filter, first: $first, after: $after) {
+		entries { actor action targetType targetId requestId error diff { field before after } }
+		endCursor hasNextPage
+	}
+}`
+
+func withRequestID(id string) client.Option {
+	return func(bd *client.Request) {
+		bd.HTTP = bd.HTTP.WithContext(requestid.With(bd.HTTP.Context(), id))
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	c := newTestClient(s)
+	admin := asUser("root", auth.RoleAdmin)
+
+	var resp map[string]any
+	c.MustPost(`mutation { updatePost(id: "post1", textPost: "edited", expectedVersion: 1) { id } }`, &resp, asUser("alice", auth.RoleUser), withRequestID("req-1"))
+	// A failed write is recorded with its error.
+	err = c.Post(`mutation { updatePost(id: "post1", textPost: "stale", expectedVersion: 1) { id } }`, &resp, asUser("alice", auth.RoleUser))
+	require.ErrorContains(t, err, `"code":"CONFLICT"`)
+	c.MustPost(`mutation { setPostStatus(id: "post1", status: HIDDEN) { id } }`, &resp, asUser("mod", auth.RoleModerator))
+
+	err = c.Post(auditQuery, &resp, asUser("mod", auth.RoleModerator))
+	require.ErrorContains(t, err, `"code":"FORBIDDEN"`)
+
+	var page auditPage
+	c.MustPost(auditQuery, &page, admin)
+	entries := page.AuditLog.Entries
+	require.Len(t, entries, 3)
+	assert.False(t, page.AuditLog.HasNextPage)
+
+	// Newest first.
+	assert.Equal(t, "mod", *entries[0].Actor)
+	assert.Equal(t, "setPostStatus", entries[0].Action)
+	require.Len(t, entries[0].Diff, 2)
+	assert.Equal(t, "status", entries[0].Diff[0].Field)
+	assert.Equal(t, `"VISIBLE"`, *entries[0].Diff[0].Before)
+	assert.Equal(t, `"HIDDEN"`, *entries[0].Diff[0].After)
+	assert.Equal(t, "version", entries[0].Diff[1].Field)
+
+	assert.Equal(t, "updatePost", entries[1].Action)
+	require.NotNil(t, entries[1].Error)
+	assert.Empty(t, entries[1].Diff)
+
+	assert.Equal(t, "alice", *entries[2].Actor)
+	assert.Equal(t, "POST", *entries[2].TargetType)
+	assert.Equal(t, ToGlobalID("Post", "post1"), *entries[2].TargetID)
+	assert.Equal(t, "req-1", *entries[2].RequestID)
+	require.Len(t, entries[2].Diff, 3)
+	assert.Equal(t, "edited", entries[2].Diff[0].Field)
+	assert.Equal(t, "textPost", entries[2].Diff[1].Field)
+	assert.Equal(t, `"edited"`, *entries[2].Diff[1].After)
+
+	c.MustPost(auditQuery, &page, admin, client.Var("filter", map[string]any{"actor": "mod"}))
+	require.Len(t, page.AuditLog.Entries, 1)
+	assert.Equal(t, "setPostStatus", page.AuditLog.Entries[0].Action)
+
+	// targetId filters by global or raw ID alike.
+	for _, id := range []string{ToGlobalID("Post", "post1"), "post1"} {
+		c.MustPost(auditQuery, &page, admin, client.Var("filter", map[string]any{"targetId": id}))
+		assert.Len(t, page.AuditLog.Entries, 3, id)
+	}
+
+	c.MustPost(auditQuery, &page, admin, client.Var("first", 2))
+	require.Len(t, page.AuditLog.Entries, 2)
+	require.True(t, page.AuditLog.HasNextPage)
+	c.MustPost(auditQuery, &page, admin, client.Var("first", 2), client.Var("after", *page.AuditLog.EndCursor))
+	require.Len(t, page.AuditLog.Entries, 1)
+	assert.Equal(t, "updatePost", page.AuditLog.Entries[0].Action)
+	assert.False(t, page.AuditLog.HasNextPage)
+}
+
+func TestAuditLog_Create(t *testing.T) {
+	c := newTestClient(storage.NewMemoryStorage())
+
+	var created struct{ CreatePost struct{ ID string } }
+	c.MustPost(`mutation { createPost(textPost: "hello", commentable: true, authorPost: "alice") { id } }`, &created, asUser("alice", auth.RoleUser))
+
+	var page auditPage
+	c.MustPost(auditQuery, &page, asUser("root", auth.RoleAdmin))
+	require.Len(t, page.AuditLog.Entries, 1)
+	entry := page.AuditLog.Entries[0]
+	assert.Equal(t, "alice", *entry.Actor)
+	assert.Equal(t, created.CreatePost.ID, *entry.TargetID)
+	fields := map[string]*string{}
+	for _, change := range entry.Diff {
+		assert.Nil(t, change.Before)
+		fields[change.Field] = change.After
+	}
+	assert.Equal(t, `"hello"`, *fields["textPost"])
+}
@@ -0,0 +1,46 @@
+package graph
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/NGerasimovvv/GraphQL/internal/httpcache"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// cacheHints returns a field middleware that lowers the HTTP cache max age
+// of the response by the @cacheControl hint of every resolved field: the
+// field's own hint, else the hint of the type it returns. Root fields and
+// fields returning objects without a hint make the response uncacheable;
+// other fields inherit from their parent.
+func cacheHints(schema *ast.Schema) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if def := fc.Field.Definition; def != nil {
+			if maxAge, ok := maxAgeHint(def.Directives); ok {
+				httpcache.Restrict(ctx, maxAge)
+			} else if typ := schema.Types[def.Type.Name()]; typ != nil {
+				if maxAge, ok := maxAgeHint(typ.Directives); ok {
+					httpcache.Restrict(ctx, maxAge)
+				} else if typ.IsCompositeType() || fc.Object == schema.Query.Name {
+					httpcache.Restrict(ctx, 0)
+				}
+			}
+		}
+		return next(ctx)
+	}
+}
+
+func maxAgeHint(directives ast.DirectiveList) (int, bool) {
+	dir := directives.ForName("cacheControl")
+	if dir == nil {
+		return 0, false
+	}
+	arg := dir.Arguments.ForName("maxAge")
+	if arg == nil {
+		return 0, false
+	}
+	maxAge, err := strconv.Atoi(arg.Value.Raw)
+	return maxAge, err == nil
+}
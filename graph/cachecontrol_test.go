@@ -0,0 +1,61 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/gateway"
+	"github.com/NGerasimovvv/GraphQL/internal/httpcache"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheHints(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	h := NewHandler(&Resolver{
+		PostGateway:    gateway.NewPostGateway(s),
+		CommentGateway: gateway.NewCommentGateway(s),
+		AuditGateway:   gateway.NewAuditGateway(s),
+		Idempotency:    gateway.NewIdempotencyGateway(s, time.Hour),
+	}, HandlerOptions{})
+	get := func(query string) (*httptest.ResponseRecorder, *httpcache.Policy) {
+		policy := &httpcache.Policy{}
+		req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(query), nil)
+		req = req.WithContext(httpcache.WithPolicy(req.Context(), policy))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec, policy
+	}
+
+	tests := []struct {
+		query  string
+		maxAge int
+	}{
+		{`{ posts { id textPost } }`, 60},
+		{`{ post(id: "post1") { id comments { id textComment } } }`, 30},
+		{`{ posts { id revisions { number } } }`, 0},
+		{`{ revisionDiff(itemId: "post1", from: 1, to: 1) { itemId } }`, 0},
+	}
+	for _, tt := range tests {
+		rec, policy := get(tt.query)
+		require.Equal(t, http.StatusOK, rec.Code, tt.query)
+		assert.NotContains(t, rec.Body.String(), `"errors"`, tt.query)
+		assert.Equal(t, tt.maxAge, policy.MaxAge(), tt.query)
+	}
+
+	rec, _ := get(`mutation { createPost(textPost: "x", commentable: true, authorPost: "alice") { id } }`)
+	assert.NotEqual(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "GET requests only allow query operations")
+	posts, err := s.GetAllPosts(ctx, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+}
@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+)
+
+// NewConfig returns the executable schema config for r with the
+// authorization directives and field costs wired in.
+func NewConfig(r *Resolver) Config {
+	cfg := Config{
+		Resolvers: rootResolver{r},
+		Directives: DirectiveRoot{
+			HasRole: hasRole,
+			IsOwner: r.isOwner,
+		},
+	}
+	cfg.Complexity.Query.RevisionDiff = func(childComplexity int, itemID string, from int, to int, mode *models.DiffMode) int {
+		return childComplexity + revisionDiffCost
+	}
+	return cfg
+}
+
+func hasRole(ctx context.Context, obj interface{}, next graphql.Resolver, role models.Role) (interface{}, error) {
+	if _, err := auth.RequireRole(ctx, auth.Role(strings.ToLower(string(role)))); err != nil {
+		return nil, err
+	}
+	return next(ctx)
+}
+
+// isOwner looks up the item named by the field argument arg, using typeArg
+// or else the field's return type to tell posts from comments.
+func (r *Resolver) isOwner(ctx context.Context, obj interface{}, next graphql.Resolver, arg *string, typeArg *models.ItemType) (interface{}, error) {
+	user, err := auth.RequireRole(ctx, auth.RoleUser)
+	if err != nil {
+		return nil, err
+	}
+	if user.Has(auth.RoleModerator) {
+		return next(ctx)
+	}
+
+	fc := graphql.GetFieldContext(ctx)
+	name := "id"
+	if arg != nil {
+		name = *arg
+	}
+	id, _ := fc.Args[name].(string)
+	typ := fc.Field.Definition.Type.Name()
+	if typeArg != nil {
+		typ = map[models.ItemType]string{models.ItemTypePost: "Post", models.ItemTypeComment: "CommentResponse"}[*typeArg]
+	}
+	var owner string
+	switch typ {
+	case "Post":
+		post, err := r.PostGateway.GetPostByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		owner = post.AuthorPost
+	case "CommentResponse":
+		comment, err := r.CommentGateway.GetCommentByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		owner = comment.AuthorComment
+	default:
+		return nil, fmt.Errorf("@isOwner is not supported on %s", typ)
+	}
+
+	if owner != user.ID {
+		return nil, auth.ErrForbidden
+	}
+	return next(ctx)
+}
@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/gateway"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testReportThreshold = 2
+
+func newTestClient(s storage.Storage) *client.Client {
+	return client.New(NewHandler(&Resolver{
+		PostGateway:     gateway.NewPostGateway(s),
+		CommentGateway:  gateway.NewCommentGateway(s),
+		ReportGateway:   gateway.NewReportGateway(s, testReportThreshold),
+		SanctionGateway: gateway.NewSanctionGateway(s),
+		AuditGateway:    gateway.NewAuditGateway(s),
+		Idempotency:     gateway.NewIdempotencyGateway(s, time.Hour),
+	}, HandlerOptions{Introspection: true, VerboseErrors: true}))
+}
+
+func asUser(id string, role auth.Role) client.Option {
+	return func(bd *client.Request) {
+		bd.HTTP = bd.HTTP.WithContext(auth.WithUser(bd.HTTP.Context(), &auth.User{ID: id, Role: role}))
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	c := newTestClient(s)
+
+	const mutation = `mutation { setPostStatus(id: "post1", status: HIDDEN) { status } }`
+	tests := []struct {
+		name string
+		opts []client.Option
+		err  error
+	}{
+		{name: "anonymous", err: auth.ErrUnauthenticated},
+		{name: "user", opts: []client.Option{asUser("alice", auth.RoleUser)}, err: auth.ErrForbidden},
+		{name: "moderator", opts: []client.Option{asUser("mod", auth.RoleModerator)}},
+		{name: "admin", opts: []client.Option{asUser("root", auth.RoleAdmin)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct{ SetPostStatus struct{ Status string } }
+			err := c.Post(mutation, &resp, tt.opts...)
+			if tt.err != nil {
+				require.ErrorContains(t, err, tt.err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "HIDDEN", resp.SetPostStatus.Status)
+		})
+	}
+}
+
+func TestIsOwner(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	comment, err := s.CreateComment(ctx, "comment", "post1", "bob")
+	require.NoError(t, err)
+	c := newTestClient(s)
+
+	const updatePost = `mutation($version: Int!) { updatePost(id: "post1", textPost: "edited", expectedVersion: $version) { textPost } }`
+	const updateComment = `mutation($id: ID!) { updateComment(id: $id, textComment: "edited", expectedVersion: 1) { textComment } }`
+	version := func(v int) client.Option { return client.Var("version", v) }
+	var resp map[string]any
+
+	err = c.Post(updatePost, &resp, version(1))
+	require.ErrorContains(t, err, auth.ErrUnauthenticated.Error())
+	err = c.Post(updatePost, &resp, version(1), asUser("bob", auth.RoleUser))
+	require.ErrorContains(t, err, auth.ErrForbidden.Error())
+	err = c.Post(updateComment, &resp, client.Var("id", comment.ID), asUser("alice", auth.RoleUser))
+	require.ErrorContains(t, err, auth.ErrForbidden.Error())
+
+	require.NoError(t, c.Post(updatePost, &resp, version(1), asUser("alice", auth.RoleUser)))
+	require.NoError(t, c.Post(updateComment, &resp, client.Var("id", comment.ID), asUser("bob", auth.RoleUser)))
+	require.NoError(t, c.Post(updatePost, &resp, version(2), asUser("mod", auth.RoleModerator)))
+
+	post, err := s.GetPostByID(ctx, "post1")
+	require.NoError(t, err)
+	assert.Equal(t, "edited", post.TextPost)
+	updated, err := s.GetCommentByID(ctx, comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "edited", updated.TextComment)
+}
+
+func TestCreate_AuthorIsCaller(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	c := newTestClient(s)
+	const mutation = `mutation { createPost(textPost: "post", commentable: true, authorPost: "alice") { authorPost } }`
+
+	var resp struct{ CreatePost struct{ AuthorPost string } }
+	require.ErrorContains(t, c.Post(mutation, &resp), `"code":"UNAUTHENTICATED"`)
+	require.ErrorContains(t, c.Post(mutation, &resp, asUser("mallory", auth.RoleUser)), `"code":"FORBIDDEN"`)
+	c.MustPost(mutation, &resp, asUser("alice", auth.RoleUser))
+	assert.Equal(t, "alice", resp.CreatePost.AuthorPost)
+
+	posts, err := s.GetAllPosts(context.Background(), nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+}
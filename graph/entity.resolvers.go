@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/visibility"
+)
+
+// The federation router resolves references to posts and comments from other
+// subgraphs through _entities. Representations carry the global ID the
+// router got from this subgraph; raw IDs are accepted too. Each type is
+// loaded with one batched read, and items the caller may not see resolve to
+// null, as in post and comment.
+
+type entityResolver struct{ *Resolver }
+
+// FindManyPostByIDs is the resolver for the findManyPostByIDs field.
+func (r *entityResolver) FindManyPostByIDs(ctx context.Context, reps []*models.PostByIDsInput) ([]*models.Post, error) {
+	ids := make([]string, len(reps))
+	for i, rep := range reps {
+		ids[i] = rawID(rep.ID)
+	}
+	posts, err := r.PostGateway.GetPostsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := (&queryResolver{r.Resolver}).loadThreads(ctx, posts); err != nil {
+		return nil, err
+	}
+
+	entities := make([]*models.Post, len(ids))
+	for i, id := range ids {
+		if post, ok := posts[id]; ok {
+			entities[i] = visibility.Post(ctx, post)
+		}
+	}
+	return entities, nil
+}
+
+// FindManyCommentResponseByIDs is the resolver for the findManyCommentResponseByIDs field.
+func (r *entityResolver) FindManyCommentResponseByIDs(ctx context.Context, reps []*models.CommentResponseByIDsInput) ([]*models.CommentResponse, error) {
+	ids := make([]string, len(reps))
+	for i, rep := range reps {
+		ids[i] = rawID(rep.ID)
+	}
+	found, err := r.CommentGateway.GetCommentsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make([]*models.CommentResponse, 0, len(found))
+	for _, comment := range found {
+		comments = append(comments, comment)
+	}
+	if err := (&queryResolver{r.Resolver}).loadReplies(ctx, comments, nil, nil); err != nil {
+		return nil, err
+	}
+
+	entities := make([]*models.CommentResponse, len(ids))
+	for i, id := range ids {
+		if comment, ok := found[id]; ok {
+			entities[i] = visibility.Comment(ctx, comment)
+		}
+	}
+	return entities, nil
+}
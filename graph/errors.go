@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/requestid"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// errorCodes are reported to clients in the "code" extension so they can
+// react to an error without parsing its message.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{auth.ErrUnauthenticated, "UNAUTHENTICATED"},
+	{auth.ErrForbidden, "FORBIDDEN"},
+	{storage.ErrBanned, "BANNED"},
+	{storage.ErrBlocked, "BLOCKED"},
+	{storage.ErrIdempotencyInProgress, "IN_PROGRESS"},
+	{storage.ErrIdempotencyKeyReused, "IDEMPOTENCY_KEY_REUSED"},
+	{storage.ErrConflict, "CONFLICT"},
+	{storage.ErrNotCommentable, "NOT_COMMENTABLE"},
+}
+
+// errInternal replaces the message of internal errors unless the handler
+// runs with verbose errors.
+var errInternal = errors.New("internal server error")
+
+// errorPresenter adds error codes to the default gqlgen error presentation.
+// Unless verbose, database and network failures are logged and reported to
+// clients only as INTERNAL.
+func errorPresenter(verbose bool) graphql.ErrorPresenterFunc {
+	return func(ctx context.Context, err error) *gqlerror.Error {
+		if !verbose && storage.IsInternal(err) {
+			log.Printf("request %s: %v", requestid.FromContext(ctx), err)
+			gqlErr := graphql.DefaultErrorPresenter(ctx, errInternal)
+			gqlErr.Extensions = map[string]interface{}{"code": "INTERNAL"}
+			return gqlErr
+		}
+		return ErrorPresenter(ctx, err)
+	}
+}
+
+// ErrorPresenter adds error codes to the default gqlgen error presentation.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	for _, c := range errorCodes {
+		if !errors.Is(err, c.err) {
+			continue
+		}
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = map[string]interface{}{}
+		}
+		gqlErr.Extensions["code"] = c.code
+		var banned *storage.BannedError
+		if errors.As(err, &banned) && banned.ExpiresAt != nil {
+			gqlErr.Extensions["expiresAt"] = banned.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		var conflict *storage.ConflictError
+		if errors.As(err, &conflict) {
+			gqlErr.Extensions["currentVersion"] = conflict.Current
+		}
+		break
+	}
+	return gqlErr
+}
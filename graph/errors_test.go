@@ -0,0 +1,30 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorPresenter_Internal(t *testing.T) {
+	ctx := context.Background()
+	dbErr := fmt.Errorf("get post: %w", &pgconn.PgError{Code: "42P01", Message: `relation "post" does not exist`})
+
+	masked := errorPresenter(false)(ctx, dbErr)
+	assert.Equal(t, "internal server error", masked.Message)
+	assert.Equal(t, "INTERNAL", masked.Extensions["code"])
+
+	verbose := errorPresenter(true)(ctx, dbErr)
+	assert.Contains(t, verbose.Message, `relation "post" does not exist`)
+
+	// Errors that are outcomes of the request keep their message and code.
+	conflict := errorPresenter(false)(ctx, &storage.ConflictError{Current: 3})
+	assert.Equal(t, "CONFLICT", conflict.Extensions["code"])
+	notFound := errorPresenter(false)(ctx, errors.New("post not found"))
+	assert.Equal(t, "post not found", notFound.Message)
+}
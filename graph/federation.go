@@ -0,0 +1,279 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/99designs/gqlgen/plugin/federation/fedruntime"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+)
+
+var (
+	ErrUnknownType  = errors.New("unknown type")
+	ErrTypeNotFound = errors.New("type not found")
+)
+
+func (ec *executionContext) __resolve__service(ctx context.Context) (fedruntime.Service, error) {
+	if ec.DisableIntrospection {
+		return fedruntime.Service{}, errors.New("federated introspection disabled")
+	}
+
+	var sdl []string
+
+	for _, src := range sources {
+		if src.BuiltIn {
+			continue
+		}
+		sdl = append(sdl, src.Input)
+	}
+
+	return fedruntime.Service{
+		SDL: strings.Join(sdl, "\n"),
+	}, nil
+}
+
+func (ec *executionContext) __resolve_entities(ctx context.Context, representations []map[string]interface{}) []fedruntime.Entity {
+	list := make([]fedruntime.Entity, len(representations))
+
+	repsMap := map[string]struct {
+		i []int
+		r []map[string]interface{}
+	}{}
+
+	// We group entities by typename so that we can parallelize their resolution.
+	// This is particularly helpful when there are entity groups in multi mode.
+	buildRepresentationGroups := func(reps []map[string]interface{}) {
+		for i, rep := range reps {
+			typeName, ok := rep["__typename"].(string)
+			if !ok {
+				// If there is no __typename, we just skip the representation;
+				// we just won't be resolving these unknown types.
+				ec.Error(ctx, errors.New("__typename must be an existing string"))
+				continue
+			}
+
+			_r := repsMap[typeName]
+			_r.i = append(_r.i, i)
+			_r.r = append(_r.r, rep)
+			repsMap[typeName] = _r
+		}
+	}
+
+	isMulti := func(typeName string) bool {
+		switch typeName {
+		case "CommentResponse":
+			return true
+		case "Post":
+			return true
+		default:
+			return false
+		}
+	}
+
+	resolveEntity := func(ctx context.Context, typeName string, rep map[string]interface{}, idx []int, i int) (err error) {
+		// we need to do our own panic handling, because we may be called in a
+		// goroutine, where the usual panic handling can't catch us
+		defer func() {
+			if r := recover(); r != nil {
+				err = ec.Recover(ctx, r)
+			}
+		}()
+
+		switch typeName {
+
+		}
+		return fmt.Errorf("%w: %s", ErrUnknownType, typeName)
+	}
+
+	resolveManyEntities := func(ctx context.Context, typeName string, reps []map[string]interface{}, idx []int) (err error) {
+		// we need to do our own panic handling, because we may be called in a
+		// goroutine, where the usual panic handling can't catch us
+		defer func() {
+			if r := recover(); r != nil {
+				err = ec.Recover(ctx, r)
+			}
+		}()
+
+		switch typeName {
+
+		case "CommentResponse":
+			resolverName, err := entityResolverNameForCommentResponse(ctx, reps[0])
+			if err != nil {
+				return fmt.Errorf(`finding resolver for Entity "CommentResponse": %w`, err)
+			}
+			switch resolverName {
+
+			case "findManyCommentResponseByIDs":
+				_reps := make([]*models.CommentResponseByIDsInput, len(reps))
+
+				for i, rep := range reps {
+					id0, err := ec.unmarshalNID2string(ctx, rep["id"])
+					if err != nil {
+						return errors.New(fmt.Sprintf("Field %s undefined in schema.", "id"))
+					}
+
+					_reps[i] = &models.CommentResponseByIDsInput{
+						ID: id0,
+					}
+				}
+
+				entities, err := ec.resolvers.Entity().FindManyCommentResponseByIDs(ctx, _reps)
+				if err != nil {
+					return err
+				}
+
+				for i, entity := range entities {
+					list[idx[i]] = entity
+				}
+				return nil
+
+			default:
+				return fmt.Errorf("unknown resolver: %s", resolverName)
+			}
+
+		case "Post":
+			resolverName, err := entityResolverNameForPost(ctx, reps[0])
+			if err != nil {
+				return fmt.Errorf(`finding resolver for Entity "Post": %w`, err)
+			}
+			switch resolverName {
+
+			case "findManyPostByIDs":
+				_reps := make([]*models.PostByIDsInput, len(reps))
+
+				for i, rep := range reps {
+					id0, err := ec.unmarshalNID2string(ctx, rep["id"])
+					if err != nil {
+						return errors.New(fmt.Sprintf("Field %s undefined in schema.", "id"))
+					}
+
+					_reps[i] = &models.PostByIDsInput{
+						ID: id0,
+					}
+				}
+
+				entities, err := ec.resolvers.Entity().FindManyPostByIDs(ctx, _reps)
+				if err != nil {
+					return err
+				}
+
+				for i, entity := range entities {
+					list[idx[i]] = entity
+				}
+				return nil
+
+			default:
+				return fmt.Errorf("unknown resolver: %s", resolverName)
+			}
+
+		default:
+			return errors.New("unknown type: " + typeName)
+		}
+	}
+
+	resolveEntityGroup := func(typeName string, reps []map[string]interface{}, idx []int) {
+		if isMulti(typeName) {
+			err := resolveManyEntities(ctx, typeName, reps, idx)
+			if err != nil {
+				ec.Error(ctx, err)
+			}
+		} else {
+			// if there are multiple entities to resolve, parallelize (similar to
+			// graphql.FieldSet.Dispatch)
+			var e sync.WaitGroup
+			e.Add(len(reps))
+			for i, rep := range reps {
+				i, rep := i, rep
+				go func(i int, rep map[string]interface{}) {
+					err := resolveEntity(ctx, typeName, rep, idx, i)
+					if err != nil {
+						ec.Error(ctx, err)
+					}
+					e.Done()
+				}(i, rep)
+			}
+			e.Wait()
+		}
+	}
+	buildRepresentationGroups(representations)
+
+	switch len(repsMap) {
+	case 0:
+		return list
+	case 1:
+		for typeName, reps := range repsMap {
+			resolveEntityGroup(typeName, reps.r, reps.i)
+		}
+		return list
+	default:
+		var g sync.WaitGroup
+		g.Add(len(repsMap))
+		for typeName, reps := range repsMap {
+			go func(typeName string, reps []map[string]interface{}, idx []int) {
+				resolveEntityGroup(typeName, reps, idx)
+				g.Done()
+			}(typeName, reps.r, reps.i)
+		}
+		g.Wait()
+		return list
+	}
+}
+
+func entityResolverNameForCommentResponse(ctx context.Context, rep map[string]interface{}) (string, error) {
+	for {
+		var (
+			m   map[string]interface{}
+			val interface{}
+			ok  bool
+		)
+		_ = val
+		// if all of the KeyFields values for this resolver are null,
+		// we shouldn't use use it
+		allNull := true
+		m = rep
+		val, ok = m["id"]
+		if !ok {
+			break
+		}
+		if allNull {
+			allNull = val == nil
+		}
+		if allNull {
+			break
+		}
+		return "findManyCommentResponseByIDs", nil
+	}
+	return "", fmt.Errorf("%w for CommentResponse", ErrTypeNotFound)
+}
+
+func entityResolverNameForPost(ctx context.Context, rep map[string]interface{}) (string, error) {
+	for {
+		var (
+			m   map[string]interface{}
+			val interface{}
+			ok  bool
+		)
+		_ = val
+		// if all of the KeyFields values for this resolver are null,
+		// we shouldn't use use it
+		allNull := true
+		m = rep
+		val, ok = m["id"]
+		if !ok {
+			break
+		}
+		if allNull {
+			allNull = val == nil
+		}
+		if allNull {
+			break
+		}
+		return "findManyPostByIDs", nil
+	}
+	return "", fmt.Errorf("%w for Post", ErrTypeNotFound)
+}
@@ -0,0 +1,142 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchStorage counts batched entity reads and fails single-item reads.
+type batchStorage struct {
+	storage.Storage
+	postBatches, commentBatches   int
+	postCommentBatches, postReads int
+}
+
+func (s *batchStorage) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*models.Post, error) {
+	s.postBatches++
+	return s.Storage.GetPostsByIDs(ctx, ids)
+}
+
+func (s *batchStorage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.CommentResponse, error) {
+	s.commentBatches++
+	return s.Storage.GetCommentsByIDs(ctx, ids)
+}
+
+func (s *batchStorage) GetCommentsByPostIDs(ctx context.Context, postIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error) {
+	s.postCommentBatches++
+	return s.Storage.GetCommentsByPostIDs(ctx, postIDs, limit, offset)
+}
+
+func (s *batchStorage) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*models.CommentResponse, error) {
+	s.postReads++
+	return s.Storage.GetCommentsByPostID(ctx, postID, limit, offset)
+}
+
+func (s *batchStorage) GetCommentTree(ctx context.Context, postID string, maxDepth, perLevelLimit int) ([]*models.CommentResponse, error) {
+	s.postReads++
+	return s.Storage.GetCommentTree(ctx, postID, maxDepth, perLevelLimit)
+}
+
+func (s *batchStorage) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
+	panic("entities must be loaded in batches")
+}
+
+func (s *batchStorage) GetCommentByID(ctx context.Context, id string) (*models.CommentResponse, error) {
+	panic("entities must be loaded in batches")
+}
+
+func TestFederationService(t *testing.T) {
+	c := newTestClient(storage.NewMemoryStorage())
+
+	var resp struct {
+		Service struct{ SDL string } `json:"_service"`
+	}
+	c.MustPost(`{ _service { sdl } }`, &resp)
+	sdl := resp.Service.SDL
+	assert.Contains(t, sdl, `@link(url: "https://specs.apollo.dev/federation/v2.3"`)
+	assert.Contains(t, sdl, `type Post implements Node @key(fields: "id")`)
+	assert.Contains(t, sdl, `type CommentResponse implements Node @key(fields: "id")`)
+}
+
+func TestFederationEntities(t *testing.T) {
+	ctx := context.Background()
+	mem := storage.NewMemoryStorage()
+	for _, id := range []string{"post1", "post2"} {
+		_, err := mem.CreatePost(ctx, id, "text of "+id, true, "alice", models.ModerationNone)
+		require.NoError(t, err)
+	}
+	_, err := mem.CreatePost(ctx, "pre", "queued", true, "alice", models.ModerationPre)
+	require.NoError(t, err)
+	top, err := mem.CreateComment(ctx, "top", "post1", "bob")
+	require.NoError(t, err)
+	reply, err := mem.CreateComment(ctx, "reply", top.ID, "carol")
+	require.NoError(t, err)
+	pending, err := mem.CreateComment(ctx, "pending", "pre", "dave")
+	require.NoError(t, err)
+	s := &batchStorage{Storage: mem}
+	c := newTestClient(s)
+
+	const query = `query($reps: [_Any!]!) { _entities(representations: $reps) {
+		__typename
+		... on Post { id textPost comments { id replies { id } } }
+		... on CommentResponse { id textComment postId }
+	} }`
+	type entity struct {
+		Typename    string `json:"__typename"`
+		ID          string
+		TextPost    string
+		TextComment string
+		PostID      string
+		Comments    []struct {
+			ID      string
+			Replies []struct{ ID string }
+		}
+	}
+	reps := []map[string]any{
+		{"__typename": "Post", "id": ToGlobalID("Post", "post1")},
+		{"__typename": "CommentResponse", "id": ToGlobalID("CommentResponse", reply.ID)},
+		{"__typename": "Post", "id": "post2"},
+		{"__typename": "Post", "id": ToGlobalID("Post", "missing")},
+		{"__typename": "CommentResponse", "id": ToGlobalID("CommentResponse", pending.ID)},
+	}
+	var resp struct {
+		Entities []*entity `json:"_entities"`
+	}
+	c.MustPost(query, &resp, client.Var("reps", reps))
+
+	require.Len(t, resp.Entities, 5)
+	post1 := resp.Entities[0]
+	require.NotNil(t, post1)
+	assert.Equal(t, "Post", post1.Typename)
+	assert.Equal(t, ToGlobalID("Post", "post1"), post1.ID)
+	assert.Equal(t, "text of post1", post1.TextPost)
+	require.Len(t, post1.Comments, 1)
+	assert.Equal(t, ToGlobalID("CommentResponse", top.ID), post1.Comments[0].ID)
+	require.Len(t, post1.Comments[0].Replies, 1)
+	assert.Equal(t, ToGlobalID("CommentResponse", reply.ID), post1.Comments[0].Replies[0].ID)
+
+	require.NotNil(t, resp.Entities[1])
+	assert.Equal(t, entity{Typename: "CommentResponse", ID: ToGlobalID("CommentResponse", reply.ID), TextComment: "reply", PostID: ToGlobalID("Post", "post1")}, *resp.Entities[1])
+	require.NotNil(t, resp.Entities[2])
+	assert.Equal(t, "text of post2", resp.Entities[2].TextPost)
+	assert.Nil(t, resp.Entities[3])
+	assert.Nil(t, resp.Entities[4])
+
+	assert.Equal(t, 1, s.postBatches)
+	assert.Equal(t, 1, s.commentBatches)
+	assert.Equal(t, 1, s.postCommentBatches)
+	assert.Zero(t, s.postReads, "comments must not be read post by post")
+
+	// Comments are only read when the client selects them.
+	*s = batchStorage{Storage: mem}
+	c.MustPost(`query($reps: [_Any!]!) { _entities(representations: $reps) { ... on Post { id } } }`, &resp, client.Var("reps", reps[:1]))
+	assert.Equal(t, 1, s.postBatches)
+	assert.Zero(t, s.postCommentBatches)
+	assert.Zero(t, s.postReads)
+}
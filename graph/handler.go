@@ -0,0 +1,90 @@
+package graph
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// HandlerOptions switches the development conveniences of the handler,
+// which production turns off.
+type HandlerOptions struct {
+	// Introspection answers __schema and __type queries. The federation
+	// router's _service query, sent with the gateway secret, is answered
+	// either way.
+	Introspection bool
+	// VerboseErrors passes database and network error messages to clients.
+	VerboseErrors bool
+}
+
+// complexityLimit is the most complexity a request may add up to. Fields
+// count 1 unless NewConfig gives them a cost.
+const complexityLimit = 1000
+
+// NewHandler returns the GraphQL handler for r with error codes, global
+// IDs and cache hints wired in. It is handler.NewDefaultServer without
+// automatic persisted queries, which the caller configures. Writes are
+// audited by the gateways.
+func NewHandler(r *Resolver, opts HandlerOptions) *handler.Server {
+	schema := NewExecutableSchema(NewConfig(r))
+	h := handler.New(schema)
+	h.AddTransport(transport.Websocket{KeepAlivePingInterval: 10 * time.Second})
+	h.AddTransport(transport.Options{})
+	h.AddTransport(transport.GET{})
+	h.AddTransport(transport.POST{})
+	h.AddTransport(transport.MultipartForm{})
+	h.SetQueryCache(lru.New(1000))
+	if opts.Introspection {
+		h.Use(extension.Introspection{})
+	} else {
+		h.Use(federationService{})
+	}
+
+	h.Use(extension.FixedComplexityLimit(complexityLimit))
+
+	h.SetErrorPresenter(errorPresenter(opts.VerboseErrors))
+	h.AroundFields(globalIDs)
+	h.AroundFields(cacheHints(schema.Schema()))
+	return h
+}
+
+// federationService lets the federation router fetch the subgraph schema
+// while introspection is off. The generated _service resolver refuses to
+// answer with introspection disabled, so it is enabled for operations that
+// select nothing but _service and come with the gateway secret.
+type federationService struct{}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = federationService{}
+
+func (federationService) ExtensionName() string {
+	return "FederationService"
+}
+
+func (federationService) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (federationService) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if rc.Operation.Operation != ast.Query || !auth.FromGateway(ctx) {
+		return nil
+	}
+	for _, sel := range rc.Operation.SelectionSet {
+		field, ok := sel.(*ast.Field)
+		if !ok || (field.Name != "_service" && field.Name != "__typename") {
+			return nil
+		}
+	}
+	rc.DisableIntrospection = false
+	return nil
+}
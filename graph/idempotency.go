@@ -0,0 +1,17 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/NGerasimovvv/GraphQL/internal/idempotency"
+)
+
+// idempotencyKey takes the client's key from the clientMutationId argument or
+// else the Idempotency-Key header and scopes it to the mutation and caller.
+func idempotencyKey(ctx context.Context, clientMutationID *string, action string) string {
+	key := idempotency.KeyFromContext(ctx)
+	if clientMutationID != nil {
+		key = *clientMutationID
+	}
+	return idempotency.Scope(ctx, action, key)
+}
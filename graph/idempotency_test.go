@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/idempotency"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withIdempotencyKey(key string) client.Option {
+	return func(bd *client.Request) {
+		bd.HTTP = bd.HTTP.WithContext(idempotency.WithKey(bd.HTTP.Context(), key))
+	}
+}
+
+func TestIdempotentCreatePost(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	c := newTestClient(s)
+
+	const mutation = `mutation($text: String!, $key: String) {
+		createPost(textPost: $text, commentable: true, authorPost: "alice", clientMutationId: $key) { id }
+	}`
+	alice := asUser("alice", auth.RoleUser)
+	var first, second struct{ CreatePost struct{ ID string } }
+	c.MustPost(mutation, &first, alice, client.Var("text", "hello"), client.Var("key", "k1"))
+	c.MustPost(mutation, &second, alice, client.Var("text", "hello"), client.Var("key", "k1"))
+	assert.Equal(t, first.CreatePost.ID, second.CreatePost.ID)
+
+	// The header works like the argument.
+	c.MustPost(mutation, &second, alice, client.Var("text", "hello"), withIdempotencyKey("k1"))
+	assert.Equal(t, first.CreatePost.ID, second.CreatePost.ID)
+
+	var resp map[string]any
+	err := c.Post(mutation, &resp, alice, client.Var("text", "changed"), client.Var("key", "k1"))
+	require.ErrorContains(t, err, `"code":"IDEMPOTENCY_KEY_REUSED"`)
+
+	c.MustPost(mutation, &second, alice, client.Var("text", "hello"))
+	assert.NotEqual(t, first.CreatePost.ID, second.CreatePost.ID)
+	posts, err := s.GetAllPosts(ctx, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, posts, 2)
+}
+
+func TestIdempotentCreateComment_RetryAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	c := newTestClient(s)
+
+	const mutation = `mutation { createComment(textComment: "hi", itemId: "post1", authorComment: "bob", clientMutationId: "k1") { id } }`
+	bob := asUser("bob", auth.RoleUser)
+	var resp struct{ CreateComment struct{ ID string } }
+	err := c.Post(mutation, &resp, bob)
+	require.ErrorContains(t, err, "item not found")
+
+	// A failed request does not hold on to its key.
+	_, err = s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	c.MustPost(mutation, &resp, bob)
+	id := resp.CreateComment.ID
+	c.MustPost(mutation, &resp, bob)
+	assert.Equal(t, id, resp.CreateComment.ID)
+	comments, err := s.GetCommentsByPostID(ctx, "post1", nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+}
+
+func TestIdempotencyKey_ScopedToCaller(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	c := newTestClient(s)
+
+	const mutation = `mutation($author: String!) {
+		createPost(textPost: "hello", commentable: true, authorPost: $author, clientMutationId: "k1") { id authorPost }
+	}`
+	var alice, bob struct {
+		CreatePost struct{ ID, AuthorPost string }
+	}
+	c.MustPost(mutation, &alice, asUser("alice", auth.RoleUser), client.Var("author", "alice"))
+	c.MustPost(mutation, &bob, asUser("bob", auth.RoleUser), client.Var("author", "bob"))
+	assert.NotEqual(t, alice.CreatePost.ID, bob.CreatePost.ID, "the same key from another caller is not a replay")
+	assert.Equal(t, "bob", bob.CreatePost.AuthorPost)
+}
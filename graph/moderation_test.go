@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModeration_Tombstones(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+	top, err := s.CreateComment(ctx, "top", "post1", "a")
+	require.NoError(t, err)
+	_, err = s.CreateComment(ctx, "reply", top.ID, "b")
+	require.NoError(t, err)
+	pending, err := s.CreateComment(ctx, "pending", "post1", "c")
+	require.NoError(t, err)
+	_, err = s.SetCommentStatus(ctx, pending.ID, models.StatusPending)
+	require.NoError(t, err)
+
+	c := newTestClient(s)
+
+	var denied struct{ SetCommentStatus struct{ Status string } }
+	err = c.Post(`mutation($id: ID!) { setCommentStatus(id: $id, status: DELETED) { status } }`, &denied, client.Var("id", top.ID), asUser("u1", auth.RoleUser))
+	require.ErrorContains(t, err, auth.ErrForbidden.Error())
+
+	var set struct{ SetCommentStatus struct{ Status string } }
+	c.MustPost(`mutation($id: ID!) { setCommentStatus(id: $id, status: DELETED) { status } }`, &set, client.Var("id", top.ID), asUser("m1", auth.RoleModerator))
+	assert.Equal(t, "DELETED", set.SetCommentStatus.Status)
+
+	type thread struct {
+		Post struct {
+			Comments []struct {
+				TextComment   string
+				AuthorComment string
+				Status        string
+				Replies       []struct{ TextComment string }
+			}
+		}
+	}
+	const query = `{ post(id: "post1") { comments { textComment authorComment status replies { textComment } } } }`
+
+	var anon thread
+	c.MustPost(query, &anon)
+	require.Len(t, anon.Post.Comments, 1)
+	assert.Equal(t, "[deleted]", anon.Post.Comments[0].TextComment)
+	assert.Equal(t, "[deleted]", anon.Post.Comments[0].AuthorComment)
+	require.Len(t, anon.Post.Comments[0].Replies, 1)
+	assert.Equal(t, "reply", anon.Post.Comments[0].Replies[0].TextComment)
+
+	var mod thread
+	c.MustPost(query, &mod, asUser("m1", auth.RoleModerator))
+	require.Len(t, mod.Post.Comments, 2)
+	assert.Equal(t, "top", mod.Post.Comments[0].TextComment)
+	assert.Equal(t, "PENDING", mod.Post.Comments[1].Status)
+
+	_, err = s.CreateComment(ctx, "too late", top.ID, "d")
+	assert.ErrorIs(t, err, storage.ErrNotCommentable)
+}
+
+func TestModerationQueue_PreModeration(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "pre", "post", true, "author", models.ModerationPre)
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "post", "post", true, "author", models.ModerationPost)
+	require.NoError(t, err)
+	first, err := s.CreateComment(ctx, "first", "pre", "a")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPending, first.Status)
+	second, err := s.CreateComment(ctx, "second", "pre", "b")
+	require.NoError(t, err)
+	published, err := s.CreateComment(ctx, "published", "post", "c")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusVisible, published.Status)
+
+	c := newTestClient(s)
+	mod := asUser("m1", auth.RoleModerator)
+
+	type queue struct {
+		ModerationQueue struct {
+			Comments    []struct{ ID string }
+			EndCursor   *string
+			HasNextPage bool
+		}
+	}
+	const query = `query($after: String) { moderationQueue(first: 2, after: $after) { comments { id } endCursor hasNextPage } }`
+
+	var page queue
+	err = c.Post(query, &page, asUser("u1", auth.RoleUser))
+	require.ErrorContains(t, err, auth.ErrForbidden.Error())
+
+	c.MustPost(query, &page, mod)
+	require.Len(t, page.ModerationQueue.Comments, 2)
+	assert.Equal(t, ToGlobalID("CommentResponse", first.ID), page.ModerationQueue.Comments[0].ID)
+	assert.Equal(t, ToGlobalID("CommentResponse", second.ID), page.ModerationQueue.Comments[1].ID)
+	assert.True(t, page.ModerationQueue.HasNextPage)
+
+	var next queue
+	c.MustPost(query, &next, client.Var("after", *page.ModerationQueue.EndCursor), mod)
+	require.Len(t, next.ModerationQueue.Comments, 1)
+	assert.Equal(t, ToGlobalID("CommentResponse", published.ID), next.ModerationQueue.Comments[0].ID)
+	assert.False(t, next.ModerationQueue.HasNextPage)
+
+	var readers struct {
+		Post struct{ Comments []struct{ ID string } }
+	}
+	c.MustPost(`{ post(id: "pre") { comments { id } } }`, &readers)
+	assert.Empty(t, readers.Post.Comments)
+
+	var resp map[string]any
+	c.MustPost(`mutation($id: ID!) { approveComment(id: $id) { status } }`, &resp, client.Var("id", first.ID), mod)
+	c.MustPost(`mutation($id: ID!) { rejectComment(id: $id) { status } }`, &resp, client.Var("id", second.ID), mod)
+	err = c.Post(`mutation($id: ID!) { approveComment(id: $id) { status } }`, &resp, client.Var("id", first.ID), mod)
+	require.ErrorContains(t, err, storage.ErrNotQueued.Error())
+
+	c.MustPost(`{ post(id: "pre") { comments { id } } }`, &readers)
+	require.Len(t, readers.Post.Comments, 1)
+	assert.Equal(t, ToGlobalID("CommentResponse", first.ID), readers.Post.Comments[0].ID)
+
+	c.MustPost(query, &page, mod)
+	require.Len(t, page.ModerationQueue.Comments, 1)
+	assert.Equal(t, ToGlobalID("CommentResponse", published.ID), page.ModerationQueue.Comments[0].ID)
+}
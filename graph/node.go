@@ -0,0 +1,198 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/visibility"
+)
+
+// idFields lists the fields that carry global IDs, by object type, with the
+// type each ID refers to. A new Node type adds its id field here and a case
+// to nodes. Fields that refer to a post or a comment depending on the
+// object, such as Report.itemId, are encoded by their resolvers with
+// itemGlobalID.
+var idFields = map[string]map[string]string{
+	"Post":            {"id": "Post"},
+	"CommentResponse": {"id": "CommentResponse", "postId": "Post", "parentCommentID": "CommentResponse"},
+}
+
+// ToGlobalID returns the Relay global ID of the object of type typ with the
+// storage ID id: base64 of "typ:id".
+func ToGlobalID(typ, id string) string {
+	return base64.StdEncoding.EncodeToString([]byte(typ + ":" + id))
+}
+
+// FromGlobalID decodes a global ID of a Node type. Anything else, such as a
+// raw storage ID, is reported with ok false.
+func FromGlobalID(gid string) (typ, id string, ok bool) {
+	data, err := base64.StdEncoding.DecodeString(gid)
+	if err != nil {
+		return "", "", false
+	}
+	typ, id, found := strings.Cut(string(data), ":")
+	if !found || id == "" || idFields[typ]["id"] != typ {
+		return "", "", false
+	}
+	return typ, id, true
+}
+
+// globalIDs translates between global and storage IDs at the schema edge:
+// ID arguments are decoded before the resolver and directives run, and ID
+// fields of Node types are encoded on the way out. Arguments of fields that
+// return Node are left alone, since node needs the type.
+func globalIDs(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc.Field.Field != nil && fc.Field.Definition != nil && fc.Field.Definition.Type.Name() != "Node" {
+		for _, arg := range fc.Field.Definition.Arguments {
+			if arg.Type.Name() == "ID" {
+				fc.Args[arg.Name] = rawIDs(fc.Args[arg.Name])
+			}
+		}
+	}
+
+	res, err := next(ctx)
+	typ, ok := idFields[fc.Object][fc.Field.Name]
+	if err != nil || !ok {
+		return res, err
+	}
+	switch id := res.(type) {
+	case string:
+		return ToGlobalID(typ, id), nil
+	case *string:
+		if id != nil {
+			gid := ToGlobalID(typ, *id)
+			return &gid, nil
+		}
+	}
+	return res, err
+}
+
+// itemGlobalID returns the global ID of the post or comment id of
+// itemType, an ItemType or audit target type. IDs of anything else, such as
+// users, are returned unchanged.
+func itemGlobalID(itemType, id string) string {
+	switch models.ItemType(itemType) {
+	case models.ItemTypePost:
+		return ToGlobalID("Post", id)
+	case models.ItemTypeComment:
+		return ToGlobalID("CommentResponse", id)
+	}
+	return id
+}
+
+// reportGlobalIDs returns report with the item ID as a global ID.
+func reportGlobalIDs(report *models.Report) *models.Report {
+	out := *report
+	out.ItemID = itemGlobalID(string(out.ItemType), out.ItemID)
+	return &out
+}
+
+// rawID returns the storage ID of a global ID and any other ID unchanged.
+func rawID(id string) string {
+	if _, raw, ok := FromGlobalID(id); ok {
+		return raw
+	}
+	return id
+}
+
+func rawIDs(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return rawID(v)
+	case *string:
+		if v != nil {
+			id := rawID(*v)
+			return &id
+		}
+	case []string:
+		ids := make([]string, len(v))
+		for i, id := range v {
+			ids[i] = rawID(id)
+		}
+		return ids
+	}
+	return v
+}
+
+// nodes fetches Nodes by global ID, or by raw ID as a post or else a
+// comment, with one batched read per type. IDs that do not exist, and
+// objects the caller may not see, resolve to nil without an error.
+func (r *queryResolver) nodes(ctx context.Context, gids []string) ([]models.Node, error) {
+	types, ids := make([]string, len(gids)), make([]string, len(gids))
+	var postIDs, commentIDs []string
+	for i, gid := range gids {
+		typ, id, ok := FromGlobalID(gid)
+		if !ok {
+			typ, id = "", gid
+		}
+		types[i], ids[i] = typ, id
+		if typ != "CommentResponse" {
+			postIDs = append(postIDs, id)
+		}
+	}
+
+	posts := map[string]*models.Post{}
+	if len(postIDs) > 0 {
+		var err error
+		if posts, err = r.PostGateway.GetPostsByIDs(ctx, postIDs); err != nil {
+			return nil, err
+		}
+	}
+	for i, typ := range types {
+		if typ == "" {
+			if _, ok := posts[ids[i]]; ok {
+				typ = "Post"
+			} else {
+				typ = "CommentResponse"
+			}
+			types[i] = typ
+		}
+		if typ == "CommentResponse" {
+			commentIDs = append(commentIDs, ids[i])
+		}
+	}
+	comments := map[string]*models.CommentResponse{}
+	if len(commentIDs) > 0 {
+		var err error
+		if comments, err = r.CommentGateway.GetCommentsByIDs(ctx, commentIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.loadThreads(ctx, posts); err != nil {
+		return nil, err
+	}
+	visible := make([]*models.CommentResponse, 0, len(comments))
+	for _, comment := range comments {
+		if !visibility.Unlisted(comment.Status) || auth.IsModerator(ctx) {
+			visible = append(visible, comment)
+		}
+	}
+	if err := r.loadReplies(ctx, visible, nil, nil); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]models.Node, len(gids))
+	for i, typ := range types {
+		switch typ {
+		case "Post":
+			if post, ok := posts[ids[i]]; ok {
+				if post = visibility.Post(ctx, post); post != nil {
+					nodes[i] = post
+				}
+			}
+		case "CommentResponse":
+			if comment, ok := comments[ids[i]]; ok {
+				if comment = visibility.Comment(ctx, comment); comment != nil {
+					nodes[i] = comment
+				}
+			}
+		}
+	}
+	return nodes, nil
+}
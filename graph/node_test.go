@@ -0,0 +1,142 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobalID(t *testing.T) {
+	gid := ToGlobalID("Post", "post1")
+	typ, id, ok := FromGlobalID(gid)
+	require.True(t, ok)
+	assert.Equal(t, "Post", typ)
+	assert.Equal(t, "post1", id)
+
+	for _, raw := range []string{"post1", "9b2f3c4e-0d1a-4c5b-8e7f-6a5b4c3d2e1f", ToGlobalID("User", "alice"), ToGlobalID("Post", "")} {
+		_, _, ok := FromGlobalID(raw)
+		assert.False(t, ok, raw)
+	}
+}
+
+func TestNode(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	top, err := s.CreateComment(ctx, "top", "post1", "bob")
+	require.NoError(t, err)
+	reply, err := s.CreateComment(ctx, "reply", top.ID, "carol")
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "pre", "queued", true, "alice", models.ModerationPre)
+	require.NoError(t, err)
+	pending, err := s.CreateComment(ctx, "pending", "pre", "dave")
+	require.NoError(t, err)
+	c := newTestClient(s)
+
+	var tree struct {
+		Post struct {
+			ID       string
+			Comments []struct {
+				ID      string
+				PostID  string
+				Replies []struct{ ID, ParentCommentID string }
+			}
+		}
+	}
+	c.MustPost(`{ post(id: "post1") { id comments { id postId replies { id parentCommentID } } } }`, &tree)
+	postID := tree.Post.ID
+	assert.Equal(t, ToGlobalID("Post", "post1"), postID)
+	require.Len(t, tree.Post.Comments, 1)
+	topID := tree.Post.Comments[0].ID
+	assert.Equal(t, ToGlobalID("CommentResponse", top.ID), topID)
+	assert.Equal(t, postID, tree.Post.Comments[0].PostID)
+	require.Len(t, tree.Post.Comments[0].Replies, 1)
+	assert.Equal(t, ToGlobalID("CommentResponse", reply.ID), tree.Post.Comments[0].Replies[0].ID)
+	assert.Equal(t, topID, tree.Post.Comments[0].Replies[0].ParentCommentID)
+
+	const nodeQuery = `query($id: ID!) { node(id: $id) { __typename id ... on Post { textPost } ... on CommentResponse { textComment } } }`
+	type node struct {
+		Typename    string `json:"__typename"`
+		ID          string
+		TextPost    string
+		TextComment string
+	}
+	var byPost struct{ Node *node }
+	c.MustPost(nodeQuery, &byPost, client.Var("id", postID))
+	require.NotNil(t, byPost.Node)
+	assert.Equal(t, node{Typename: "Post", ID: postID, TextPost: "post"}, *byPost.Node)
+
+	var byComment struct{ Node *node }
+	c.MustPost(nodeQuery, &byComment, client.Var("id", topID))
+	require.NotNil(t, byComment.Node)
+	assert.Equal(t, node{Typename: "CommentResponse", ID: topID, TextComment: "top"}, *byComment.Node)
+
+	// Raw IDs still resolve, and the result carries the global ID.
+	var byRaw struct{ Node *node }
+	c.MustPost(nodeQuery, &byRaw, client.Var("id", reply.ID))
+	require.NotNil(t, byRaw.Node)
+	assert.Equal(t, "reply", byRaw.Node.TextComment)
+	assert.Equal(t, ToGlobalID("CommentResponse", reply.ID), byRaw.Node.ID)
+
+	// Items the caller may not see, or that do not exist, are plain nulls.
+	var nodes struct{ Nodes []*struct{ ID string } }
+	c.MustPost(`query($ids: [ID!]!) { nodes(ids: $ids) { id } }`, &nodes,
+		client.Var("ids", []string{topID, ToGlobalID("CommentResponse", pending.ID), postID, "missing"}))
+	require.Len(t, nodes.Nodes, 4)
+	assert.Equal(t, topID, nodes.Nodes[0].ID)
+	assert.Nil(t, nodes.Nodes[1])
+	assert.Equal(t, postID, nodes.Nodes[2].ID)
+	assert.Nil(t, nodes.Nodes[3])
+
+	var missing struct{ Node *node }
+	c.MustPost(nodeQuery, &missing, client.Var("id", ToGlobalID("Post", "missing")))
+	assert.Nil(t, missing.Node)
+}
+
+func TestNode_RawPostIDReadOnce(t *testing.T) {
+	ctx := context.Background()
+	mem := storage.NewMemoryStorage()
+	_, err := mem.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	s := &batchStorage{Storage: mem}
+	c := newTestClient(s)
+
+	var resp struct{ Node struct{ TextPost string } }
+	c.MustPost(`{ node(id: "post1") { ... on Post { textPost } } }`, &resp)
+	assert.Equal(t, "post", resp.Node.TextPost)
+	assert.Equal(t, 1, s.postBatches)
+}
+
+func TestGlobalIDArguments(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	c := newTestClient(s)
+
+	var created struct{ CreateComment struct{ ID, PostID string } }
+	c.MustPost(`mutation($item: ID!) { createComment(textComment: "hi", itemId: $item, authorComment: "bob") { id postId } }`,
+		&created, asUser("bob", auth.RoleUser), client.Var("item", ToGlobalID("Post", "post1")))
+	assert.Equal(t, ToGlobalID("Post", "post1"), created.CreateComment.PostID)
+	_, id, ok := FromGlobalID(created.CreateComment.ID)
+	require.True(t, ok)
+	stored, err := s.GetCommentByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "post1", stored.PostID)
+
+	var updated struct{ UpdateComment struct{ TextComment string } }
+	c.MustPost(`mutation($id: ID!) { updateComment(id: $id, textComment: "edited", expectedVersion: 1) { textComment } }`,
+		&updated, client.Var("id", created.CreateComment.ID), asUser("mod", auth.RoleModerator))
+	assert.Equal(t, "edited", updated.UpdateComment.TextComment)
+
+	var byRaw struct{ Comment struct{ TextComment string } }
+	c.MustPost(`query($id: ID!) { comment(id: $id) { textComment } }`, &byRaw, client.Var("id", id))
+	assert.Equal(t, "edited", byRaw.Comment.TextComment)
+}
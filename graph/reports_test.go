@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport_AutoHide(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+	spam, err := s.CreateComment(ctx, "buy now", "post1", "spammer")
+	require.NoError(t, err)
+	c := newTestClient(s)
+
+	const report = `mutation($id: ID!) { report(itemId: $id, reason: SPAM) { itemId itemType reporter } }`
+	var resp struct {
+		Report struct{ ItemID, ItemType, Reporter string }
+	}
+
+	err = c.Post(report, &resp, client.Var("id", spam.ID))
+	require.ErrorContains(t, err, auth.ErrUnauthenticated.Error())
+
+	c.MustPost(report, &resp, client.Var("id", spam.ID), asUser("u1", auth.RoleUser))
+	assert.Equal(t, ToGlobalID("CommentResponse", spam.ID), resp.Report.ItemID)
+	assert.Equal(t, "COMMENT", resp.Report.ItemType)
+	assert.Equal(t, "u1", resp.Report.Reporter)
+	err = c.Post(report, &resp, client.Var("id", spam.ID), asUser("u1", auth.RoleUser))
+	require.ErrorContains(t, err, storage.ErrAlreadyReported.Error())
+
+	comment, err := s.GetCommentByID(ctx, spam.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusVisible, comment.Status)
+
+	c.MustPost(report, &resp, client.Var("id", spam.ID), asUser("u2", auth.RoleUser))
+	comment, err = s.GetCommentByID(ctx, spam.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusHidden, comment.Status)
+
+	c.MustPost(`mutation { report(itemId: "post1", reason: OTHER, note: "off") { itemType reporter } }`, &resp, asUser("u3", auth.RoleUser))
+
+	var groups struct {
+		Reports []struct {
+			ItemID   string
+			ItemType string
+			Count    int
+			Reports  []struct{ Reporter string }
+		}
+	}
+	const query = `{ reports { itemId itemType count reports { reporter } } }`
+	err = c.Post(query, &groups, asUser("u1", auth.RoleUser))
+	require.ErrorContains(t, err, auth.ErrForbidden.Error())
+
+	c.MustPost(query, &groups, asUser("m1", auth.RoleModerator))
+	require.Len(t, groups.Reports, 2)
+	assert.Equal(t, ToGlobalID("CommentResponse", spam.ID), groups.Reports[0].ItemID)
+	assert.Equal(t, "COMMENT", groups.Reports[0].ItemType)
+	assert.Equal(t, 2, groups.Reports[0].Count)
+	assert.Equal(t, "u1", groups.Reports[0].Reports[0].Reporter)
+	assert.Equal(t, ToGlobalID("Post", "post1"), groups.Reports[1].ItemID)
+	assert.Equal(t, 1, groups.Reports[1].Count)
+}
@@ -11,13 +11,29 @@ import (
 )
 
 type MockPostGateway struct {
-	CreatePostFunc  func(ctx context.Context, id string, textPost string, commentable bool, authorPost string) (*models.Post, error)
-	GetAllPostsFunc func(ctx context.Context, limit *int, offset *int) ([]*models.Post, error)
-	GetPostByIDFunc func(ctx context.Context, id string) (*models.Post, error)
+	CreatePostFunc    func(ctx context.Context, id string, textPost string, commentable bool, authorPost string, moderation models.Moderation) (*models.Post, error)
+	GetAllPostsFunc   func(ctx context.Context, limit *int, offset *int) ([]*models.Post, error)
+	GetPostByIDFunc   func(ctx context.Context, id string) (*models.Post, error)
+	GetPostsByIDsFunc func(ctx context.Context, ids []string) (map[string]*models.Post, error)
+	UpdatePostFunc    func(ctx context.Context, id string, textPost string, expectedVersion int) (*models.Post, error)
+	SetPostStatusFunc func(ctx context.Context, id string, status models.Status) (*models.Post, error)
+	GetRevisionsFunc  func(ctx context.Context, id string) ([]*models.Revision, error)
 }
 
-func (m *MockPostGateway) CreatePost(ctx context.Context, id string, textPost string, commentable bool, authorPost string) (*models.Post, error) {
-	return m.CreatePostFunc(ctx, id, textPost, commentable, authorPost)
+func (m *MockPostGateway) GetRevisions(ctx context.Context, id string) ([]*models.Revision, error) {
+	return m.GetRevisionsFunc(ctx, id)
+}
+
+func (m *MockPostGateway) UpdatePost(ctx context.Context, id string, textPost string, expectedVersion int) (*models.Post, error) {
+	return m.UpdatePostFunc(ctx, id, textPost, expectedVersion)
+}
+
+func (m *MockPostGateway) SetPostStatus(ctx context.Context, id string, status models.Status) (*models.Post, error) {
+	return m.SetPostStatusFunc(ctx, id, status)
+}
+
+func (m *MockPostGateway) CreatePost(ctx context.Context, id string, textPost string, commentable bool, authorPost string, moderation models.Moderation) (*models.Post, error) {
+	return m.CreatePostFunc(ctx, id, textPost, commentable, authorPost, moderation)
 }
 
 func (m *MockPostGateway) GetAllPosts(ctx context.Context, limit *int, offset *int) ([]*models.Post, error) {
@@ -28,12 +44,45 @@ func (m *MockPostGateway) GetPostByID(ctx context.Context, id string) (*models.P
 	return m.GetPostByIDFunc(ctx, id)
 }
 
+func (m *MockPostGateway) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*models.Post, error) {
+	return m.GetPostsByIDsFunc(ctx, ids)
+}
+
 type MockCommentGateway struct {
-	CreateCommentFunc         func(ctx context.Context, commentText string, itemID string, authorComment string) (*models.CommentResponse, error)
-	GetCommentsByPostIDFunc   func(ctx context.Context, postID string, limit *int, offset *int) ([]*models.CommentResponse, error)
-	GetCommentsByParentIDFunc func(ctx context.Context, parentID string, limit *int, offset *int) ([]*models.CommentResponse, error)
-	GetCommentByIDFunc        func(ctx context.Context, id string) (*models.CommentResponse, error)
-	GetAllCommentsFunc        func(ctx context.Context, limit *int, offset *int) ([]*models.CommentResponse, error)
+	CreateCommentFunc          func(ctx context.Context, commentText string, itemID string, authorComment string) (*models.CommentResponse, error)
+	GetCommentsByPostIDFunc    func(ctx context.Context, postID string, limit *int, offset *int) ([]*models.CommentResponse, error)
+	GetCommentsByPostIDsFunc   func(ctx context.Context, postIDs []string, limit *int, offset *int) (map[string][]*models.CommentResponse, error)
+	GetCommentsByParentIDFunc  func(ctx context.Context, parentID string, limit *int, offset *int) ([]*models.CommentResponse, error)
+	GetCommentsByParentIDsFunc func(ctx context.Context, parentIDs []string, limit *int, offset *int) (map[string][]*models.CommentResponse, error)
+	GetCommentTreeFunc         func(ctx context.Context, postID string, maxDepth int, perLevelLimit int) ([]*models.CommentResponse, error)
+	GetCommentByIDFunc         func(ctx context.Context, id string) (*models.CommentResponse, error)
+	GetCommentsByIDsFunc       func(ctx context.Context, ids []string) (map[string]*models.CommentResponse, error)
+	GetAllCommentsFunc         func(ctx context.Context, limit *int, offset *int) ([]*models.CommentResponse, error)
+	GetModerationQueueFunc     func(ctx context.Context, first int, after string) (*models.ModerationQueue, error)
+	ReviewCommentFunc          func(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error)
+	UpdateCommentFunc          func(ctx context.Context, id string, textComment string, expectedVersion int) (*models.CommentResponse, error)
+	SetCommentStatusFunc       func(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error)
+	GetRevisionsFunc           func(ctx context.Context, id string) ([]*models.Revision, error)
+}
+
+func (m *MockCommentGateway) GetRevisions(ctx context.Context, id string) ([]*models.Revision, error) {
+	return m.GetRevisionsFunc(ctx, id)
+}
+
+func (m *MockCommentGateway) GetModerationQueue(ctx context.Context, first int, after string) (*models.ModerationQueue, error) {
+	return m.GetModerationQueueFunc(ctx, first, after)
+}
+
+func (m *MockCommentGateway) ReviewComment(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	return m.ReviewCommentFunc(ctx, id, status)
+}
+
+func (m *MockCommentGateway) UpdateComment(ctx context.Context, id string, textComment string, expectedVersion int) (*models.CommentResponse, error) {
+	return m.UpdateCommentFunc(ctx, id, textComment, expectedVersion)
+}
+
+func (m *MockCommentGateway) SetCommentStatus(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	return m.SetCommentStatusFunc(ctx, id, status)
 }
 
 func (m *MockCommentGateway) CreateComment(ctx context.Context, commentText string, itemID string, authorComment string) (*models.CommentResponse, error) {
@@ -46,7 +95,7 @@ func (r *Resolver) CreateComment(ctx context.Context, commentText string, itemID
 
 func (r *Resolver) CreatePost(ctx context.Context, textPost string, commentable bool, authorPost string) (*models.Post, error) {
 	id := uuid.New().String() // Генерация нового ID для поста
-	return r.PostGateway.CreatePost(ctx, id, textPost, commentable, authorPost)
+	return r.PostGateway.CreatePost(ctx, id, textPost, commentable, authorPost, models.ModerationNone)
 }
 
 func (r *Resolver) Posts(ctx context.Context, limit *int, offset *int) ([]*models.Post, error) {
@@ -127,21 +176,37 @@ func (m *MockCommentGateway) GetCommentsByPostID(ctx context.Context, postID str
 	return m.GetCommentsByPostIDFunc(ctx, postID, limit, offset)
 }
 
+func (m *MockCommentGateway) GetCommentsByPostIDs(ctx context.Context, postIDs []string, limit *int, offset *int) (map[string][]*models.CommentResponse, error) {
+	return m.GetCommentsByPostIDsFunc(ctx, postIDs, limit, offset)
+}
+
 func (m *MockCommentGateway) GetCommentsByParentID(ctx context.Context, parentID string, limit *int, offset *int) ([]*models.CommentResponse, error) {
 	return m.GetCommentsByParentIDFunc(ctx, parentID, limit, offset)
 }
 
+func (m *MockCommentGateway) GetCommentsByParentIDs(ctx context.Context, parentIDs []string, limit *int, offset *int) (map[string][]*models.CommentResponse, error) {
+	return m.GetCommentsByParentIDsFunc(ctx, parentIDs, limit, offset)
+}
+
+func (m *MockCommentGateway) GetCommentTree(ctx context.Context, postID string, maxDepth int, perLevelLimit int) ([]*models.CommentResponse, error) {
+	return m.GetCommentTreeFunc(ctx, postID, maxDepth, perLevelLimit)
+}
+
 func (m *MockCommentGateway) GetCommentByID(ctx context.Context, id string) (*models.CommentResponse, error) {
 	return m.GetCommentByIDFunc(ctx, id)
 }
 
+func (m *MockCommentGateway) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.CommentResponse, error) {
+	return m.GetCommentsByIDsFunc(ctx, ids)
+}
+
 func (m *MockCommentGateway) GetAllComments(ctx context.Context, limit *int, offset *int) ([]*models.CommentResponse, error) {
 	return m.GetAllCommentsFunc(ctx, limit, offset)
 }
 
 func TestCreatePost(t *testing.T) {
 	mockPostGateway := &MockPostGateway{
-		CreatePostFunc: func(ctx context.Context, id string, textPost string, commentable bool, authorPost string) (*models.Post, error) {
+		CreatePostFunc: func(ctx context.Context, id string, textPost string, commentable bool, authorPost string, moderation models.Moderation) (*models.Post, error) {
 			return &models.Post{ID: id, TextPost: textPost, Commentable: commentable, AuthorPost: authorPost}, nil
 		},
 	}
@@ -295,7 +360,7 @@ func TestComment(t *testing.T) {
 
 func TestCreatePost_Error(t *testing.T) {
 	mockPostGateway := &MockPostGateway{
-		CreatePostFunc: func(ctx context.Context, id string, textPost string, commentable bool, authorPost string) (*models.Post, error) {
+		CreatePostFunc: func(ctx context.Context, id string, textPost string, commentable bool, authorPost string, moderation models.Moderation) (*models.Post, error) {
 			return nil, errors.New("ошибка создания поста")
 		},
 	}
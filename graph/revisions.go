@@ -0,0 +1,50 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/textdiff"
+	"github.com/NGerasimovvv/GraphQL/internal/visibility"
+)
+
+// itemRevisions returns the type and revisions of a post or comment the
+// caller may read in full.
+func (r *Resolver) itemRevisions(ctx context.Context, id string) (models.ItemType, []*models.Revision, error) {
+	if post, err := r.PostGateway.GetPostByID(ctx, id); err == nil {
+		if visibility.TextHidden(ctx, post.Status) {
+			return "", nil, errors.New("item not found")
+		}
+		revisions, err := r.PostGateway.GetRevisions(ctx, id)
+		return models.ItemTypePost, revisions, err
+	}
+	comment, err := r.CommentGateway.GetCommentByID(ctx, id)
+	if err != nil || visibility.TextHidden(ctx, comment.Status) {
+		return "", nil, errors.New("item not found")
+	}
+	revisions, err := r.CommentGateway.GetRevisions(ctx, id)
+	return models.ItemTypeComment, revisions, err
+}
+
+// revisionDiffCost is the complexity of a revisionDiff field. Every call
+// computes a diff, so the cost keeps a request from aliasing many of them.
+const revisionDiffCost = 100
+
+var diffOps = map[textdiff.Op]models.DiffOp{
+	textdiff.Equal:  models.DiffOpEqual,
+	textdiff.Insert: models.DiffOpInsert,
+	textdiff.Delete: models.DiffOpDelete,
+}
+
+func diffChunks(a, b string, mode models.DiffMode) []*models.DiffChunk {
+	diff := textdiff.Lines
+	if mode == models.DiffModeWord {
+		diff = textdiff.Words
+	}
+	chunks := []*models.DiffChunk{}
+	for _, c := range diff(a, b) {
+		chunks = append(chunks, &models.DiffChunk{Op: diffOps[c.Op], Text: c.Text})
+	}
+	return chunks
+}
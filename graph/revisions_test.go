@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevisions(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "hello world", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	comment, err := s.CreateComment(ctx, "first", "post1", "bob")
+	require.NoError(t, err)
+	c := newTestClient(s)
+	alice := asUser("alice", auth.RoleUser)
+
+	for i, text := range []string{"hello brave world", "hello brave world", "hello brave new world"} {
+		var resp map[string]any
+		c.MustPost(`mutation($text: String!, $version: Int!) { updatePost(id: "post1", textPost: $text, expectedVersion: $version) { id } }`,
+			&resp, alice, client.Var("text", text), client.Var("version", i+1))
+	}
+
+	type revisions []struct {
+		Number int
+		Text   string
+	}
+	var post struct {
+		Post struct {
+			Edited    bool
+			Revisions revisions
+			Comments  []struct {
+				Edited    bool
+				Revisions revisions
+			}
+		}
+	}
+	const query = `{ post(id: "post1") { edited revisions { number text } comments { edited revisions { number text } } } }`
+	c.MustPost(query, &post)
+	assert.True(t, post.Post.Edited)
+	// Saving the same text again does not make a revision.
+	assert.Equal(t, revisions{{1, "hello world"}, {2, "hello brave world"}, {3, "hello brave new world"}}, post.Post.Revisions)
+	require.Len(t, post.Post.Comments, 1)
+	assert.False(t, post.Post.Comments[0].Edited)
+	assert.Equal(t, revisions{{1, comment.TextComment}}, post.Post.Comments[0].Revisions)
+
+	type chunks []struct {
+		Op   string
+		Text string
+	}
+	var diff struct {
+		RevisionDiff struct {
+			ItemID string
+			Chunks chunks
+		}
+	}
+	c.MustPost(`{ revisionDiff(itemId: "post1", from: 1, to: 3, mode: WORD) { itemId chunks { op text } } }`, &diff)
+	assert.Equal(t, ToGlobalID("Post", "post1"), diff.RevisionDiff.ItemID)
+	assert.Equal(t, chunks{{"EQUAL", "hello "}, {"INSERT", "brave new "}, {"EQUAL", "world"}}, diff.RevisionDiff.Chunks)
+
+	c.MustPost(`{ revisionDiff(itemId: "post1", from: 1, to: 2) { chunks { op text } } }`, &diff)
+	assert.Equal(t, chunks{{"DELETE", "hello world"}, {"INSERT", "hello brave world"}}, diff.RevisionDiff.Chunks)
+
+	var resp map[string]any
+	err = c.Post(`{ revisionDiff(itemId: "post1", from: 1, to: 4) { itemId } }`, &resp)
+	require.ErrorContains(t, err, "revision 4 not found")
+
+	// Each diff is costly, so a request cannot alias many of them.
+	var aliased strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&aliased, `d%d: revisionDiff(itemId: "post1", from: 1, to: 3) { itemId } `, i)
+	}
+	err = c.Post("{ "+aliased.String()+"}", &resp)
+	require.ErrorContains(t, err, "COMPLEXITY_LIMIT_EXCEEDED")
+
+	// Hidden posts keep their history from everyone but moderators.
+	_, err = s.SetPostStatus(ctx, "post1", models.StatusHidden)
+	require.NoError(t, err)
+	c.MustPost(query, &post)
+	assert.Empty(t, post.Post.Revisions)
+	err = c.Post(`{ revisionDiff(itemId: "post1", from: 1, to: 2) { itemId } }`, &resp)
+	require.ErrorContains(t, err, "item not found")
+	c.MustPost(query, &post, asUser("mod", auth.RoleModerator))
+	assert.Len(t, post.Post.Revisions, 3)
+}
@@ -0,0 +1,105 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBanUser(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	c := newTestClient(s)
+	mod := asUser("mod", auth.RoleModerator)
+
+	var resp map[string]any
+	err = c.Post(`mutation { banUser(userId: "bob") { userId } }`, &resp, asUser("alice", auth.RoleUser))
+	require.ErrorContains(t, err, `"code":"FORBIDDEN"`)
+
+	var ban struct {
+		BanUser struct {
+			Kind      string
+			CreatedBy string
+			ExpiresAt *string
+		}
+	}
+	c.MustPost(`mutation { banUser(userId: "bob", kind: MUTE, durationSeconds: 3600) { kind createdBy expiresAt } }`, &ban, mod)
+	assert.Equal(t, "MUTE", ban.BanUser.Kind)
+	assert.Equal(t, "mod", ban.BanUser.CreatedBy)
+	require.NotNil(t, ban.BanUser.ExpiresAt)
+
+	// A mute still allows posting.
+	bob := asUser("bob", auth.RoleUser)
+	const createPost = `mutation { createPost(textPost: "post", commentable: true, authorPost: "bob") { id } }`
+	const createComment = `mutation { createComment(textComment: "hi", itemId: "post1", authorComment: "bob") { id } }`
+	c.MustPost(createPost, &resp, bob)
+	err = c.Post(createComment, &resp, bob)
+	require.ErrorContains(t, err, `"code":"BANNED"`)
+	require.ErrorContains(t, err, `"expiresAt"`)
+
+	c.MustPost(`mutation { banUser(userId: "bob") { kind } }`, &resp, mod)
+	err = c.Post(createPost, &resp, bob)
+	require.ErrorContains(t, err, `"code":"BANNED"`)
+	// The ban follows the signed-in user, not the name they write under.
+	err = c.Post(`mutation { createPost(textPost: "post", commentable: true, authorPost: "carol") { id } }`, &resp, bob)
+	require.ErrorContains(t, err, `"code":"FORBIDDEN"`)
+
+	var unban struct{ UnbanUser bool }
+	c.MustPost(`mutation { unbanUser(userId: "bob") }`, &unban, mod)
+	assert.True(t, unban.UnbanUser)
+	c.MustPost(createComment, &resp, bob)
+}
+
+func TestBlockUser(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	top, err := s.CreateComment(ctx, "top", "post1", "carol")
+	require.NoError(t, err)
+	c := newTestClient(s)
+
+	const block = `mutation { blockUser(postId: "post1", userId: "bob") }`
+	var resp map[string]any
+	err = c.Post(block, &resp, asUser("bob", auth.RoleUser))
+	require.ErrorContains(t, err, `"code":"FORBIDDEN"`)
+	c.MustPost(block, &resp, asUser("alice", auth.RoleUser))
+
+	err = c.Post(`mutation { createComment(textComment: "hi", itemId: "post1", authorComment: "bob") { id } }`, &resp, asUser("bob", auth.RoleUser))
+	require.ErrorContains(t, err, `"code":"BLOCKED"`)
+	// The block covers replies anywhere under the post.
+	_, err = s.CreateComment(ctx, "reply", top.ID, "bob")
+	assert.ErrorIs(t, err, storage.ErrBlocked)
+
+	var unblock struct{ UnblockUser bool }
+	c.MustPost(`mutation { unblockUser(postId: "post1", userId: "bob") }`, &unblock, asUser("alice", auth.RoleUser))
+	assert.True(t, unblock.UnblockUser)
+	_, err = s.CreateComment(ctx, "reply", top.ID, "bob")
+	assert.NoError(t, err)
+
+	// The audit log says which post a block applied under.
+	var page auditPage
+	c.MustPost(auditQuery, &page, asUser("root", auth.RoleAdmin), client.Var("filter", map[string]any{"targetId": "bob"}))
+	require.Len(t, page.AuditLog.Entries, 2)
+	for i, action := range []string{"unblockUser", "blockUser"} {
+		entry := page.AuditLog.Entries[i]
+		assert.Equal(t, action, entry.Action)
+		assert.Equal(t, "bob", *entry.TargetID)
+		fields := map[string]*string{}
+		for _, change := range entry.Diff {
+			fields[change.Field] = change.Before
+			if change.After != nil {
+				fields[change.Field] = change.After
+			}
+		}
+		assert.Equal(t, `"post1"`, *fields["postId"], action)
+	}
+}
@@ -2,26 +2,99 @@ package graph
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
 	"github.com/NGerasimovvv/GraphQL/internal/gateway"
+	"github.com/NGerasimovvv/GraphQL/internal/idempotency"
 	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/visibility"
 	"github.com/google/uuid"
 )
 
-func (r *mutationResolver) CreatePost(ctx context.Context, textPost string, commentable bool, authorPost string) (*models.Post, error) {
-	id := uuid.New().String()
-	post, err := r.PostGateway.CreatePost(ctx, id, textPost, commentable, authorPost)
-	if err != nil {
-		return nil, err
+func (r *mutationResolver) CreatePost(ctx context.Context, textPost string, commentable bool, authorPost string, moderation *models.Moderation, clientMutationID *string) (*models.Post, error) {
+	mode := models.ModerationNone
+	if moderation != nil {
+		mode = *moderation
 	}
-	return post, nil
+	key := idempotencyKey(ctx, clientMutationID, "createPost")
+	return gateway.Idempotent(ctx, r.Idempotency, key, idempotency.Fingerprint(textPost, commentable, mode), func() (*models.Post, error) {
+		id := uuid.New().String()
+		return r.PostGateway.CreatePost(ctx, id, textPost, commentable, authorPost, mode)
+	})
+}
+
+func (r *mutationResolver) CreateComment(ctx context.Context, commentText string, itemID string, authorComment string, clientMutationID *string) (*models.CommentResponse, error) {
+	key := idempotencyKey(ctx, clientMutationID, "createComment")
+	return gateway.Idempotent(ctx, r.Idempotency, key, idempotency.Fingerprint(commentText, itemID), func() (*models.CommentResponse, error) {
+		return r.CommentGateway.CreateComment(ctx, commentText, itemID, authorComment)
+	})
+}
+
+func (r *mutationResolver) UpdatePost(ctx context.Context, id string, textPost string, expectedVersion int) (*models.Post, error) {
+	return r.PostGateway.UpdatePost(ctx, id, textPost, expectedVersion)
+}
+
+func (r *mutationResolver) UpdateComment(ctx context.Context, id string, textComment string, expectedVersion int) (*models.CommentResponse, error) {
+	return r.CommentGateway.UpdateComment(ctx, id, textComment, expectedVersion)
+}
+
+func (r *mutationResolver) SetPostStatus(ctx context.Context, id string, status models.Status) (*models.Post, error) {
+	return r.PostGateway.SetPostStatus(ctx, id, status)
+}
+
+func (r *mutationResolver) SetCommentStatus(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	return r.CommentGateway.SetCommentStatus(ctx, id, status)
+}
+
+func (r *mutationResolver) ApproveComment(ctx context.Context, id string) (*models.CommentResponse, error) {
+	return r.CommentGateway.ReviewComment(ctx, id, models.StatusVisible)
 }
 
-func (r *mutationResolver) CreateComment(ctx context.Context, commentText string, itemID string, authorComment string) (*models.CommentResponse, error) {
-	comment, err := r.CommentGateway.CreateComment(ctx, commentText, itemID, authorComment)
+func (r *mutationResolver) RejectComment(ctx context.Context, id string) (*models.CommentResponse, error) {
+	return r.CommentGateway.ReviewComment(ctx, id, models.StatusRejected)
+}
+
+func (r *mutationResolver) Report(ctx context.Context, itemID string, reason models.ReportReason, note *string) (*models.Report, error) {
+	user, _ := auth.UserFromContext(ctx)
+	report, err := r.ReportGateway.Report(ctx, itemID, user.ID, reason, note)
 	if err != nil {
 		return nil, err
 	}
-	return comment, nil
+	return reportGlobalIDs(report), nil
+}
+
+func (r *mutationResolver) BanUser(ctx context.Context, userID string, kind *models.SanctionKind, durationSeconds *int, reason *string) (*models.Sanction, error) {
+	moderator, _ := auth.UserFromContext(ctx)
+	k := models.SanctionKindBan
+	if kind != nil {
+		k = *kind
+	}
+	var duration time.Duration
+	if durationSeconds != nil {
+		if *durationSeconds <= 0 {
+			return nil, errors.New("durationSeconds must be positive")
+		}
+		duration = time.Duration(*durationSeconds) * time.Second
+	}
+	return r.SanctionGateway.BanUser(ctx, userID, k, duration, reason, moderator.ID)
+}
+
+func (r *mutationResolver) UnbanUser(ctx context.Context, userID string) (bool, error) {
+	return r.SanctionGateway.UnbanUser(ctx, userID)
+}
+
+func (r *mutationResolver) BlockUser(ctx context.Context, postID string, userID string) (bool, error) {
+	if err := r.SanctionGateway.BlockUser(ctx, postID, userID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) UnblockUser(ctx context.Context, postID string, userID string) (bool, error) {
+	return r.SanctionGateway.UnblockUser(ctx, postID, userID)
 }
 
 func (r *queryResolver) Posts(ctx context.Context, limit *int, offset *int) ([]*models.Post, error) {
@@ -37,14 +110,11 @@ func (r *queryResolver) Posts(ctx context.Context, limit *int, offset *int) ([]*
 			return nil, err
 		}
 
-		for _, comment := range post.Comments {
-			comment.Replies, err = r.CommentGateway.GetCommentsByParentID(ctx, comment.ID, limit, offset)
-			if err != nil {
-				return nil, err
-			}
+		if err := r.loadReplies(ctx, post.Comments, limit, offset); err != nil {
+			return nil, err
 		}
 	}
-	return posts, nil
+	return visibility.Posts(ctx, posts), nil
 }
 
 func (r *queryResolver) Post(ctx context.Context, id string, limit *int, offset *int) (*models.Post, error) {
@@ -52,20 +122,90 @@ func (r *queryResolver) Post(ctx context.Context, id string, limit *int, offset
 	if err != nil {
 		return nil, err
 	}
+	if visibility.Unlisted(post.Status) && !auth.IsModerator(ctx) {
+		return nil, errors.New("post not found")
+	}
+	if err := r.loadComments(ctx, post, limit, offset); err != nil {
+		return nil, err
+	}
+	return visibility.Post(ctx, post), nil
+}
+
+// loadComments fills in the comments of post down to the depth of replies
+// the client selected.
+func (r *queryResolver) loadComments(ctx context.Context, post *models.Post, limit *int, offset *int) error {
+	var err error
+	// Nested replies are fetched as one tree; offset paging still goes level by level.
+	if depth := repliesDepth(ctx); depth > 0 && offset == nil {
+		perLevelLimit := 0
+		if limit != nil {
+			perLevelLimit = *limit
+		}
+		post.Comments, err = r.CommentGateway.GetCommentTree(ctx, post.ID, depth+1, perLevelLimit)
+		return err
+	}
 
 	post.Comments, err = r.CommentGateway.GetCommentsByPostID(ctx, post.ID, limit, offset)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return r.loadReplies(ctx, post.Comments, limit, offset)
+}
 
-	for _, comment := range post.Comments {
-		comment.Replies, err = r.CommentGateway.GetCommentsByParentID(ctx, comment.ID, limit, offset)
-		if err != nil {
-			return nil, err
+// loadThreads fills in the comments of the posts the caller may see, and the
+// replies the client selected under them, with one batched read per level
+// of the threads. Nothing is read unless the client selected comments.
+func (r *queryResolver) loadThreads(ctx context.Context, byID map[string]*models.Post) error {
+	if !commentsSelected(ctx) {
+		return nil
+	}
+	var posts []*models.Post
+	var ids []string
+	for id, post := range byID {
+		if !visibility.Unlisted(post.Status) || auth.IsModerator(ctx) {
+			posts = append(posts, post)
+			ids = append(ids, id)
 		}
 	}
+	if len(posts) == 0 {
+		return nil
+	}
+	byPost, err := r.CommentGateway.GetCommentsByPostIDs(ctx, ids, nil, nil)
+	if err != nil {
+		return err
+	}
 
-	return post, nil
+	depth := repliesDepth(ctx)
+	var level []*models.CommentResponse
+	for _, post := range posts {
+		post.Comments = byPost[post.ID]
+		// With replies selected, comments holds the top of each thread, as in post.
+		if depth > 0 {
+			post.Comments = roots(post.Comments)
+		}
+		level = append(level, post.Comments...)
+	}
+	for ; depth > 0 && len(level) > 0; depth-- {
+		if err := r.loadReplies(ctx, level, nil, nil); err != nil {
+			return err
+		}
+		var next []*models.CommentResponse
+		for _, comment := range level {
+			next = append(next, comment.Replies...)
+		}
+		level = next
+	}
+	return nil
+}
+
+func roots(comments []*models.CommentResponse) []*models.CommentResponse {
+	top := make([]*models.CommentResponse, 0, len(comments))
+	for _, comment := range comments {
+		if comment.ParentCommentID == nil {
+			top = append(top, comment)
+		}
+	}
+	return top
 }
 
 func (r *queryResolver) Comments(ctx context.Context, limit *int, offset *int) ([]*models.CommentResponse, error) {
@@ -74,14 +214,11 @@ func (r *queryResolver) Comments(ctx context.Context, limit *int, offset *int) (
 		return nil, err
 	}
 
-	for _, comment := range comments {
-		comment.Replies, err = r.CommentGateway.GetCommentsByParentID(ctx, comment.ID, limit, offset)
-		if err != nil {
-			return nil, err
-		}
+	if err := r.loadReplies(ctx, comments, limit, offset); err != nil {
+		return nil, err
 	}
 
-	return comments, nil
+	return visibility.Comments(ctx, comments), nil
 }
 
 func (r *queryResolver) Comment(ctx context.Context, id string, limit *int, offset *int) (*models.CommentResponse, error) {
@@ -89,23 +226,179 @@ func (r *queryResolver) Comment(ctx context.Context, id string, limit *int, offs
 	if err != nil {
 		return nil, err
 	}
+	if visibility.Unlisted(comment.Status) && !auth.IsModerator(ctx) {
+		return nil, errors.New("comment not found")
+	}
 
 	comment.Replies, err = r.CommentGateway.GetCommentsByParentID(ctx, comment.ID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 
-	return comment, nil
+	return visibility.Comment(ctx, comment), nil
+}
+
+func (r *queryResolver) ModerationQueue(ctx context.Context, first *int, after *string) (*models.ModerationQueue, error) {
+	n := 20
+	if first != nil && *first >= 0 {
+		n = *first
+	}
+	cursor := ""
+	if after != nil {
+		cursor = *after
+	}
+	return r.CommentGateway.GetModerationQueue(ctx, n, cursor)
+}
+
+func (r *queryResolver) Reports(ctx context.Context, limit *int, offset *int) ([]*models.ReportGroup, error) {
+	groups, err := r.ReportGateway.GetReports(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*models.ReportGroup, len(groups))
+	for i, group := range groups {
+		g := *group
+		g.ItemID = itemGlobalID(string(g.ItemType), g.ItemID)
+		g.Reports = make([]*models.Report, len(group.Reports))
+		for j, report := range group.Reports {
+			g.Reports[j] = reportGlobalIDs(report)
+		}
+		out[i] = &g
+	}
+	return out, nil
+}
+
+func (r *queryResolver) AuditLog(ctx context.Context, filter *models.AuditFilter, first *int, after *string) (*models.AuditLogPage, error) {
+	n := 50
+	if first != nil && *first >= 0 {
+		n = *first
+	}
+	cursor := ""
+	if after != nil {
+		cursor = *after
+	}
+	// The log keeps raw IDs; targetId is filtered and shown as a global ID.
+	if filter != nil && filter.TargetID != nil {
+		f := *filter
+		targetID := rawID(*f.TargetID)
+		f.TargetID = &targetID
+		filter = &f
+	}
+	page, err := r.AuditGateway.GetAuditLog(ctx, filter, n, cursor)
+	if err != nil {
+		return nil, err
+	}
+	out := *page
+	out.Entries = make([]*models.AuditEntry, len(page.Entries))
+	for i, entry := range page.Entries {
+		e := *entry
+		if e.TargetType != nil && e.TargetID != nil {
+			gid := itemGlobalID(*e.TargetType, *e.TargetID)
+			e.TargetID = &gid
+		}
+		out.Entries[i] = &e
+	}
+	return &out, nil
+}
+
+// Node is the resolver for the node field.
+func (r *queryResolver) Node(ctx context.Context, id string) (models.Node, error) {
+	nodes, err := r.nodes(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// Nodes is the resolver for the nodes field.
+func (r *queryResolver) Nodes(ctx context.Context, ids []string) ([]models.Node, error) {
+	return r.nodes(ctx, ids)
+}
+
+func (r *queryResolver) RevisionDiff(ctx context.Context, itemID string, from int, to int, mode *models.DiffMode) (*models.RevisionDiff, error) {
+	itemType, revisions, err := r.itemRevisions(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	text := func(n int) (string, error) {
+		if n < 1 || n > len(revisions) {
+			return "", fmt.Errorf("revision %d not found", n)
+		}
+		return revisions[n-1].Text, nil
+	}
+	a, err := text(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := text(to)
+	if err != nil {
+		return nil, err
+	}
+	diffMode := models.DiffModeLine
+	if mode != nil {
+		diffMode = *mode
+	}
+	return &models.RevisionDiff{ItemID: itemGlobalID(string(itemType), itemID), From: from, To: to, Chunks: diffChunks(a, b, diffMode)}, nil
+}
+
+func (r *postResolver) Revisions(ctx context.Context, obj *models.Post) ([]*models.Revision, error) {
+	if visibility.TextHidden(ctx, obj.Status) {
+		return []*models.Revision{}, nil
+	}
+	return r.PostGateway.GetRevisions(ctx, obj.ID)
+}
+
+func (r *commentResponseResolver) Revisions(ctx context.Context, obj *models.CommentResponse) ([]*models.Revision, error) {
+	if visibility.TextHidden(ctx, obj.Status) {
+		return []*models.Revision{}, nil
+	}
+	return r.CommentGateway.GetRevisions(ctx, obj.ID)
+}
+
+func (r *queryResolver) loadReplies(ctx context.Context, comments []*models.CommentResponse, limit *int, offset *int) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	ids := make([]string, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	replies, err := r.CommentGateway.GetCommentsByParentIDs(ctx, ids, limit, offset)
+	if err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		comment.Replies = replies[comment.ID]
+	}
+	return nil
 }
 
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// rootResolver serves the object resolvers. They cannot be methods of
+// Resolver, which the tests extend with methods named after the types.
+type rootResolver struct{ *Resolver }
+
+func (r rootResolver) Post() PostResolver { return &postResolver{r.Resolver} }
+
+func (r rootResolver) CommentResponse() CommentResponseResolver {
+	return &commentResponseResolver{r.Resolver}
+}
+
+func (r rootResolver) Entity() EntityResolver { return &entityResolver{r.Resolver} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type postResolver struct{ *Resolver }
+type commentResponseResolver struct{ *Resolver }
 
 type Resolver struct {
-	CommentGateway gateway.CommentGateway
-	PostGateway    gateway.PostGateway
+	CommentGateway  gateway.CommentGateway
+	PostGateway     gateway.PostGateway
+	ReportGateway   gateway.ReportGateway
+	SanctionGateway gateway.SanctionGateway
+	AuditGateway    gateway.AuditGateway
+	Idempotency     *gateway.IdempotencyGateway
 }
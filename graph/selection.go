@@ -0,0 +1,49 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// repliesDepth reports how many levels of replies the client selected under
+// post.comments, or 0 when no replies are requested.
+func repliesDepth(ctx context.Context) int {
+	f, ok := postComments(ctx)
+	if !ok {
+		return 0
+	}
+	return nestedDepth(graphql.GetOperationContext(ctx), f.Selections, "replies")
+}
+
+// commentsSelected reports whether the client selected post.comments.
+func commentsSelected(ctx context.Context) bool {
+	_, ok := postComments(ctx)
+	return ok
+}
+
+func postComments(ctx context.Context) (graphql.CollectedField, bool) {
+	if !graphql.HasOperationContext(ctx) || graphql.GetFieldContext(ctx) == nil {
+		return graphql.CollectedField{}, false
+	}
+	for _, f := range graphql.CollectFieldsCtx(ctx, []string{"Post"}) {
+		if f.Name == "comments" {
+			return f, true
+		}
+	}
+	return graphql.CollectedField{}, false
+}
+
+func nestedDepth(opCtx *graphql.OperationContext, selections ast.SelectionSet, name string) int {
+	depth := 0
+	for _, f := range graphql.CollectFields(opCtx, selections, []string{"CommentResponse"}) {
+		if f.Name != name {
+			continue
+		}
+		if d := 1 + nestedDepth(opCtx, f.Selections, name); d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
@@ -0,0 +1,45 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPost_NestedRepliesFromTree(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+	top, err := s.CreateComment(ctx, "top", "post1", "a")
+	require.NoError(t, err)
+	reply, err := s.CreateComment(ctx, "reply", top.ID, "b")
+	require.NoError(t, err)
+	_, err = s.CreateComment(ctx, "reply to reply", reply.ID, "c")
+	require.NoError(t, err)
+
+	c := newTestClient(s)
+
+	var resp struct {
+		Post struct {
+			Comments []struct {
+				TextComment string
+				Replies     []struct {
+					TextComment string
+					Replies     []struct{ TextComment string }
+				}
+			}
+		}
+	}
+	c.MustPost(`{ post(id: "post1") { comments { textComment replies { textComment replies { textComment } } } } }`, &resp)
+
+	require.Len(t, resp.Post.Comments, 1)
+	require.Len(t, resp.Post.Comments[0].Replies, 1)
+	assert.Equal(t, "reply", resp.Post.Comments[0].Replies[0].TextComment)
+	require.Len(t, resp.Post.Comments[0].Replies[0].Replies, 1)
+	assert.Equal(t, "reply to reply", resp.Post.Comments[0].Replies[0].Replies[0].TextComment)
+}
@@ -0,0 +1,45 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+	comment, err := s.CreateComment(ctx, "comment", "post1", "bob")
+	require.NoError(t, err)
+	c := newTestClient(s)
+	mod1, mod2 := asUser("mod1", auth.RoleModerator), asUser("mod2", auth.RoleModerator)
+
+	const updatePost = `mutation($text: String!) { updatePost(id: "post1", textPost: $text, expectedVersion: 1) { version } }`
+	var updated struct{ UpdatePost struct{ Version int } }
+	c.MustPost(updatePost, &updated, mod1, client.Var("text", "first"))
+	assert.Equal(t, 2, updated.UpdatePost.Version)
+
+	// The second moderator edited the version both of them loaded.
+	var resp map[string]any
+	err = c.Post(updatePost, &resp, mod2, client.Var("text", "second"))
+	require.ErrorContains(t, err, `"code":"CONFLICT"`)
+	require.ErrorContains(t, err, `"currentVersion":2`)
+	post, err := s.GetPostByID(ctx, "post1")
+	require.NoError(t, err)
+	assert.Equal(t, "first", post.TextPost)
+
+	// Status changes count as changes too.
+	_, err = s.SetCommentStatus(ctx, comment.ID, models.StatusHidden)
+	require.NoError(t, err)
+	err = c.Post(`mutation($id: ID!) { updateComment(id: $id, textComment: "edited", expectedVersion: 1) { version } }`,
+		&resp, mod1, client.Var("id", comment.ID))
+	require.ErrorContains(t, err, `"currentVersion":2`)
+}
@@ -0,0 +1,15 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache is a byte-oriented key/value store with per-entry expiry. It mirrors
+// the subset of Redis commands the storage cache needs (GET, SET EX, DEL), so
+// a networked implementation can be swapped in for the in-process LRU.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU is an in-process Cache bounded by entry count. Entries also expire
+// after their TTL; expired entries are dropped lazily on access or when they
+// reach the tail of the eviction list.
+type LRU struct {
+	mu         sync.Mutex
+	size       int
+	defaultTTL time.Duration
+	ll         *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewLRU(size int, defaultTTL time.Duration) *LRU {
+	return &LRU{
+		size:       size,
+		defaultTTL: defaultTTL,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*entry)
+	if c.now().After(e.expires) {
+		c.removeElement(el)
+		return nil, false, nil
+	}
+	c.ll.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.defaultTTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		e.value = value
+		e.expires = expires
+		c.ll.MoveToFront(el)
+		return nil
+	}
+	c.items[key] = c.ll.PushFront(&entry{key: key, value: value, expires: expires})
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el)
+		}
+	}
+	return nil
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2, time.Minute)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), 0))
+	_, ok, _ := c.Get(ctx, "a")
+	require.True(t, ok)
+	require.NoError(t, c.Set(ctx, "c", []byte("3"), 0))
+
+	_, ok, _ = c.Get(ctx, "b")
+	assert.False(t, ok)
+	_, ok, _ = c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRU_Expiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewLRU(10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	require.NoError(t, c.Set(ctx, "short", []byte("1"), time.Second))
+	require.NoError(t, c.Set(ctx, "default", []byte("2"), 0))
+
+	now = now.Add(2 * time.Second)
+	_, ok, _ := c.Get(ctx, "short")
+	assert.False(t, ok)
+	v, ok, _ := c.Get(ctx, "default")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("2"), v)
+
+	require.NoError(t, c.Delete(ctx, "default"))
+	_, ok, _ = c.Get(ctx, "default")
+	assert.False(t, ok)
+}
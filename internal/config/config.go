@@ -1,94 +1,572 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StorageMemory   = "memory"
+	StoragePostgres = "postgres"
+
+	CacheNone   = "none"
+	CacheMemory = "memory"
+
+	ProfileDev  = "dev"
+	ProfileProd = "prod"
+
+	redacted = "REDACTED"
 )
 
 type Config struct {
-	Postgres *PostgresConfig
-	Storage  *StorageTypeConfig
+	Server      *ServerConfig      `yaml:"server"`
+	Postgres    *PostgresConfig    `yaml:"postgres"`
+	Storage     *StorageTypeConfig `yaml:"storage"`
+	Cache       *CacheConfig       `yaml:"cache"`
+	Moderation  *ModerationConfig  `yaml:"moderation"`
+	Filter      *FilterConfig      `yaml:"filter"`
+	Idempotency *IdempotencyConfig `yaml:"idempotency"`
+
+	PersistedQueries *PersistedQueriesConfig `yaml:"persistedQueries"`
+}
+
+type ServerConfig struct {
+	// Profile is dev or prod. prod turns off the playground, introspection
+	// and internal error messages, runs gin in release mode and sends
+	// secure default headers.
+	Profile string `yaml:"profile"`
+	// AllowedOrigins may call the API from browsers; "*" allows any origin
+	// and an empty list turns CORS off.
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+	// GatewaySecret is shared with the API gateway, which sends it with the
+	// identity headers; requests without it are anonymous. Required in prod,
+	// an empty secret in dev trusts the headers from any caller.
+	GatewaySecret string `yaml:"gatewaySecret"`
 }
 
+// Dev reports whether the development conveniences are on.
+func (c *ServerConfig) Dev() bool { return c.Profile == ProfileDev }
+
 type StorageTypeConfig struct {
-	StorageType string
+	StorageType string `yaml:"type"`
+}
+
+type CacheConfig struct {
+	Type string        `yaml:"type"`
+	Size int           `yaml:"size"`
+	TTL  time.Duration `yaml:"ttl"`
+}
+
+type ModerationConfig struct {
+	// ReportThreshold is how many distinct users must report an item before
+	// it is hidden automatically; 0 turns auto-hiding off.
+	ReportThreshold int `yaml:"reportThreshold"`
+	// SanctionSweepInterval is how often expired bans and mutes are deleted.
+	SanctionSweepInterval time.Duration `yaml:"sanctionSweepInterval"`
+}
+
+type IdempotencyConfig struct {
+	// Window is how long an idempotency key replays its first result;
+	// 0 turns idempotency keys off.
+	Window time.Duration `yaml:"window"`
+	// SweepInterval is how often expired keys are deleted.
+	SweepInterval time.Duration `yaml:"sweepInterval"`
+}
+
+type PersistedQueriesConfig struct {
+	// CacheSize is how many automatic persisted queries are kept; 0 turns
+	// APQ off.
+	CacheSize int `yaml:"cacheSize"`
+	// TTL is how long an unused persisted query stays cached.
+	TTL time.Duration `yaml:"ttl"`
+	// Manifest is a persisted query manifest loaded at startup. With Strict
+	// only its operations are executed and APQ registration is disabled.
+	Manifest string `yaml:"manifest"`
+	Strict   bool   `yaml:"strict"`
+}
+
+// FilterConfig enables the built-in content filters applied to comments.
+// Actions are reject, flag or mask; links and duplicates cannot be masked.
+type FilterConfig struct {
+	Normalize         bool          `yaml:"normalize"`
+	BannedWords       []string      `yaml:"bannedWords"`
+	BannedWordsAction string        `yaml:"bannedWordsAction"`
+	MaxLinks          int           `yaml:"maxLinks"`
+	LinksAction       string        `yaml:"linksAction"`
+	DuplicateWindow   time.Duration `yaml:"duplicateWindow"`
+	DuplicateAction   string        `yaml:"duplicateAction"`
 }
 
 type PostgresConfig struct {
-	PostgresPort     string
-	PostgresHost     string
-	DatabaseName     string
-	PostgresUser     string
-	PostgresPassword string
-}
-
-func LoadConfig() *Config {
-	postgresConfig := loadPostgresConfig()
-	storageTypeConfig := loadStorageTypeConfig()
-	logger := log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime)
-	logger.Printf("Postgres Port: %s", postgresConfig.PostgresPort)
-	logger.Printf("StorageType: %s", storageTypeConfig.StorageType)
+	DSN              string `yaml:"dsn"`
+	PostgresPort     string `yaml:"port"`
+	PostgresHost     string `yaml:"host"`
+	DatabaseName     string `yaml:"db"`
+	PostgresUser     string `yaml:"user"`
+	PostgresPassword string `yaml:"password"`
+	SSLMode          string `yaml:"sslmode"`
+
+	MaxConns         int           `yaml:"maxConns"`
+	MinConns         int           `yaml:"minConns"`
+	ConnMaxLifetime  time.Duration `yaml:"connMaxLifetime"`
+	ConnMaxIdleTime  time.Duration `yaml:"connMaxIdleTime"`
+	StatementTimeout time.Duration `yaml:"statementTimeout"`
+
+	// ReplicaDSNs are read-only replicas that serve Get* queries.
+	ReplicaDSNs           []string      `yaml:"replicas"`
+	ReplicaHealthInterval time.Duration `yaml:"replicaHealthInterval"`
+
+	// StartupTimeout bounds how long the app waits for Postgres to come up.
+	StartupTimeout time.Duration `yaml:"startupTimeout"`
+	// ReadRetries is how many times an idempotent read is retried on a transient error.
+	ReadRetries int `yaml:"readRetries"`
+}
+
+// binding ties a config field to its environment variable and command-line flag.
+type binding struct {
+	env   string
+	flag  string
+	usage string
+	ptr   any
+}
+
+func defaultConfig() *Config {
 	return &Config{
-		Postgres: postgresConfig,
-		Storage:  storageTypeConfig,
+		Postgres: &PostgresConfig{
+			PostgresPort: "5432",
+			PostgresHost: "localhost",
+			SSLMode:      "disable",
+
+			MaxConns:         25,
+			MinConns:         2,
+			ConnMaxLifetime:  30 * time.Minute,
+			ConnMaxIdleTime:  5 * time.Minute,
+			StatementTimeout: 10 * time.Second,
+			StartupTimeout:   30 * time.Second,
+			ReadRetries:      2,
+
+			ReplicaHealthInterval: 5 * time.Second,
+		},
+		Server:  &ServerConfig{Profile: ProfileDev},
+		Storage: &StorageTypeConfig{StorageType: StorageMemory},
+		Cache: &CacheConfig{
+			Type: CacheNone,
+			Size: 10000,
+			TTL:  30 * time.Second,
+		},
+		Moderation: &ModerationConfig{
+			ReportThreshold:       3,
+			SanctionSweepInterval: time.Minute,
+		},
+		Filter: &FilterConfig{
+			Normalize:         true,
+			BannedWordsAction: "mask",
+			LinksAction:       "flag",
+			DuplicateAction:   "reject",
+		},
+		Idempotency: &IdempotencyConfig{
+			Window:        24 * time.Hour,
+			SweepInterval: 10 * time.Minute,
+		},
+		PersistedQueries: &PersistedQueriesConfig{
+			CacheSize: 1000,
+			TTL:       24 * time.Hour,
+		},
 	}
 }
 
-func loadPostgresConfig() *PostgresConfig {
-	const opt = "LoadPostgresConfig"
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("%s: %v", opt, err)
+func (c *Config) bindings() []binding {
+	return []binding{
+		{"APP_PROFILE", "profile", "environment profile: dev or prod", &c.Server.Profile},
+		{"CORS_ALLOWED_ORIGINS", "cors-allowed-origins", "comma-separated origins allowed to call the API from browsers, * for any", &c.Server.AllowedOrigins},
+		{"GATEWAY_SECRET", "gateway-secret", "secret the API gateway sends with the identity headers", &c.Server.GatewaySecret},
+		{"STORAGE_TYPE", "storage", "storage backend: memory or postgres", &c.Storage.StorageType},
+		{"POSTGRES_DSN", "postgres-dsn", "full Postgres connection string, overrides the other postgres options", &c.Postgres.DSN},
+		{"POSTGRES_HOST", "postgres-host", "Postgres host", &c.Postgres.PostgresHost},
+		{"POSTGRES_PORT", "postgres-port", "Postgres port", &c.Postgres.PostgresPort},
+		{"POSTGRES_DB", "postgres-db", "Postgres database name", &c.Postgres.DatabaseName},
+		{"POSTGRES_USER", "postgres-user", "Postgres user", &c.Postgres.PostgresUser},
+		{"POSTGRES_PASSWORD", "postgres-password", "Postgres password", &c.Postgres.PostgresPassword},
+		{"POSTGRES_SSLMODE", "postgres-sslmode", "Postgres sslmode", &c.Postgres.SSLMode},
+		{"POSTGRES_MAX_CONNS", "postgres-max-conns", "maximum pool size", &c.Postgres.MaxConns},
+		{"POSTGRES_MIN_CONNS", "postgres-min-conns", "connections kept open while idle", &c.Postgres.MinConns},
+		{"POSTGRES_CONN_MAX_LIFETIME", "postgres-conn-max-lifetime", "maximum connection lifetime, 0 is unlimited", &c.Postgres.ConnMaxLifetime},
+		{"POSTGRES_CONN_MAX_IDLE_TIME", "postgres-conn-max-idle-time", "maximum connection idle time, 0 is unlimited", &c.Postgres.ConnMaxIdleTime},
+		{"POSTGRES_STATEMENT_TIMEOUT", "postgres-statement-timeout", "server-side statement timeout, 0 disables it", &c.Postgres.StatementTimeout},
+		{"POSTGRES_STARTUP_TIMEOUT", "postgres-startup-timeout", "how long to wait for Postgres at startup", &c.Postgres.StartupTimeout},
+		{"POSTGRES_READ_RETRIES", "postgres-read-retries", "retries of idempotent reads on transient errors", &c.Postgres.ReadRetries},
+		{"POSTGRES_REPLICA_DSNS", "postgres-replica-dsns", "comma-separated read replica connection strings", &c.Postgres.ReplicaDSNs},
+		{"POSTGRES_REPLICA_HEALTH_INTERVAL", "postgres-replica-health-interval", "how often replicas are health-checked", &c.Postgres.ReplicaHealthInterval},
+		{"CACHE_TYPE", "cache", "read cache in front of the storage: none or memory", &c.Cache.Type},
+		{"CACHE_SIZE", "cache-size", "maximum number of cached entries", &c.Cache.Size},
+		{"CACHE_TTL", "cache-ttl", "how long cached reads stay fresh", &c.Cache.TTL},
+		{"FILTER_NORMALIZE", "filter-normalize", "match filters against text with look-alike characters folded", &c.Filter.Normalize},
+		{"FILTER_BANNED_WORDS", "filter-banned-words", "comma-separated words filtered from comments", &c.Filter.BannedWords},
+		{"FILTER_BANNED_WORDS_ACTION", "filter-banned-words-action", "reject, flag or mask comments with banned words", &c.Filter.BannedWordsAction},
+		{"FILTER_MAX_LINKS", "filter-max-links", "links allowed in a comment, 0 is unlimited", &c.Filter.MaxLinks},
+		{"FILTER_LINKS_ACTION", "filter-links-action", "reject or flag comments with too many links", &c.Filter.LinksAction},
+		{"FILTER_DUPLICATE_WINDOW", "filter-duplicate-window", "how long a repeated comment by the same author is caught, 0 disables it", &c.Filter.DuplicateWindow},
+		{"FILTER_DUPLICATE_ACTION", "filter-duplicate-action", "reject or flag repeated comments", &c.Filter.DuplicateAction},
+		{"MODERATION_REPORT_THRESHOLD", "report-threshold", "distinct reports that hide an item automatically, 0 disables it", &c.Moderation.ReportThreshold},
+		{"MODERATION_SANCTION_SWEEP_INTERVAL", "sanction-sweep-interval", "how often expired bans and mutes are deleted", &c.Moderation.SanctionSweepInterval},
+		{"IDEMPOTENCY_WINDOW", "idempotency-window", "how long idempotency keys replay the first result, 0 disables them", &c.Idempotency.Window},
+		{"IDEMPOTENCY_SWEEP_INTERVAL", "idempotency-sweep-interval", "how often expired idempotency keys are deleted", &c.Idempotency.SweepInterval},
+		{"APQ_CACHE_SIZE", "apq-cache-size", "automatic persisted queries kept in memory, 0 disables APQ", &c.PersistedQueries.CacheSize},
+		{"APQ_TTL", "apq-ttl", "how long an unused persisted query stays cached", &c.PersistedQueries.TTL},
+		{"PERSISTED_QUERIES_MANIFEST", "persisted-queries-manifest", "persisted query manifest loaded at startup", &c.PersistedQueries.Manifest},
+		{"PERSISTED_QUERIES_STRICT", "persisted-queries-strict", "execute only operations from the manifest", &c.PersistedQueries.Strict},
 	}
-	logger := log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime)
+}
 
-	port, ok := os.LookupEnv("POSTGRES_PORT")
-	if !ok {
-		logger.Fatal("POSTGRES! Can't read PORT")
+// LoadConfig builds the effective configuration from defaults, an optional
+// YAML file, environment variables (and .env if present) and command-line
+// flags, each layer overriding the previous one.
+func LoadConfig(args []string) (*Config, error) {
+	const op = "config.LoadConfig"
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	host, ok := os.LookupEnv("POSTGRES_HOST")
-	if !ok {
-		logger.Fatal("POSTGRES! Can't read HOST")
+	cfg := defaultConfig()
+	bindings := cfg.bindings()
+
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	values := make([]*flagValue, len(bindings))
+	for i, b := range bindings {
+		values[i] = &flagValue{ptr: b.ptr}
+		fs.Var(values[i], b.flag, b.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	password, ok := os.LookupEnv("POSTGRES_PASSWORD")
-	if !ok {
-		logger.Fatal("POSTGRES! Can't read PASSWORD")
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 	}
 
-	db, ok := os.LookupEnv("POSTGRES_DB")
-	if !ok {
-		logger.Fatal("POSTGRES! Can't read DB")
+	for _, b := range bindings {
+		if v, ok := os.LookupEnv(b.env); ok {
+			if err := setValue(b.ptr, v); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", op, b.env, err)
+			}
+		}
 	}
 
-	user, ok := os.LookupEnv("POSTGRES_USER")
-	if !ok {
-		logger.Fatal("POSTGRES! Can't read USER")
+	for _, v := range values {
+		if v.set {
+			if err := setValue(v.ptr, v.raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
 	}
 
-	return &PostgresConfig{
-		PostgresPort:     port,
-		PostgresHost:     host,
-		DatabaseName:     db,
-		PostgresUser:     user,
-		PostgresPassword: password,
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+
+	return cfg, nil
 }
 
-func loadStorageTypeConfig() *StorageTypeConfig {
-	err := godotenv.Load(".env")
-	const opt = "loadStorageConfig"
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatalf("%s: %v", opt, err)
+		return err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	if c.Server.Profile != ProfileDev && c.Server.Profile != ProfileProd {
+		return fmt.Errorf("server: unknown profile %q", c.Server.Profile)
+	}
+	if c.Server.Profile == ProfileProd && c.Server.GatewaySecret == "" {
+		return errors.New("server: prod requires a gatewaySecret")
+	}
+	if err := c.Cache.validate(); err != nil {
+		return err
+	}
+	if err := c.Filter.validate(); err != nil {
+		return err
 	}
-	logger := log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime)
+	if c.Moderation.ReportThreshold < 0 {
+		return errors.New("moderation: reportThreshold must not be negative")
+	}
+	if c.Moderation.SanctionSweepInterval <= 0 {
+		return errors.New("moderation: sanctionSweepInterval must be positive")
+	}
+	if c.Idempotency.Window < 0 {
+		return errors.New("idempotency: window must not be negative")
+	}
+	if c.Idempotency.SweepInterval <= 0 {
+		return errors.New("idempotency: sweepInterval must be positive")
+	}
+	if c.PersistedQueries.CacheSize < 0 {
+		return errors.New("persistedQueries: cacheSize must not be negative")
+	}
+	if c.PersistedQueries.CacheSize > 0 && c.PersistedQueries.TTL <= 0 {
+		return errors.New("persistedQueries: ttl must be positive")
+	}
+	if c.PersistedQueries.Strict && c.PersistedQueries.Manifest == "" {
+		return errors.New("persistedQueries: strict mode requires a manifest")
+	}
+	switch c.Storage.StorageType {
+	case StorageMemory:
+		return nil
+	case StoragePostgres:
+		return c.Postgres.validate()
+	default:
+		return fmt.Errorf("unknown storage type %q", c.Storage.StorageType)
+	}
+}
+
+func (c *CacheConfig) validate() error {
+	switch c.Type {
+	case CacheNone:
+		return nil
+	case CacheMemory:
+		if c.Size <= 0 {
+			return errors.New("cache: size must be positive")
+		}
+		if c.TTL <= 0 {
+			return errors.New("cache: ttl must be positive")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown cache type %q", c.Type)
+	}
+}
+
+// validate checks the actions even of filters that are off, so a typo is
+// caught before the filter is turned on.
+func (c *FilterConfig) validate() error {
+	actions := []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"bannedWordsAction", c.BannedWordsAction, []string{"reject", "flag", "mask"}},
+		{"linksAction", c.LinksAction, []string{"reject", "flag"}},
+		{"duplicateAction", c.DuplicateAction, []string{"reject", "flag"}},
+	}
+	for _, a := range actions {
+		if !slices.Contains(a.allowed, strings.ToLower(a.value)) {
+			return fmt.Errorf("filter: %s must be one of %s, got %q", a.name, strings.Join(a.allowed, ", "), a.value)
+		}
+	}
+	if c.MaxLinks < 0 {
+		return errors.New("filter: maxLinks must not be negative")
+	}
+	if c.DuplicateWindow < 0 {
+		return errors.New("filter: duplicateWindow must not be negative")
+	}
+	return nil
+}
+
+func (c *PostgresConfig) validate() error {
+	if c.DSN != "" {
+		return c.validatePool()
+	}
+	var missing []string
+	if c.PostgresHost == "" {
+		missing = append(missing, "host")
+	}
+	if c.PostgresPort == "" {
+		missing = append(missing, "port")
+	}
+	if c.DatabaseName == "" {
+		missing = append(missing, "db")
+	}
+	if c.PostgresUser == "" {
+		missing = append(missing, "user")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("postgres: missing %s (or set a dsn)", strings.Join(missing, ", "))
+	}
+	if _, err := strconv.Atoi(c.PostgresPort); err != nil {
+		return fmt.Errorf("postgres: invalid port %q", c.PostgresPort)
+	}
+	return c.validatePool()
+}
+
+func (c *PostgresConfig) validatePool() error {
+	if c.MaxConns <= 0 || c.MinConns < 0 {
+		return errors.New("postgres: maxConns must be positive and minConns must not be negative")
+	}
+	if c.MinConns > c.MaxConns {
+		return errors.New("postgres: minConns must not exceed maxConns")
+	}
+	if c.ConnMaxLifetime < 0 || c.ConnMaxIdleTime < 0 || c.StatementTimeout < 0 {
+		return errors.New("postgres: durations must not be negative")
+	}
+	if c.StartupTimeout <= 0 {
+		return errors.New("postgres: startupTimeout must be positive")
+	}
+	if c.ReadRetries < 0 {
+		return errors.New("postgres: readRetries must not be negative")
+	}
+	if len(c.ReplicaDSNs) > 0 && c.ReplicaHealthInterval <= 0 {
+		return errors.New("postgres: replicaHealthInterval must be positive")
+	}
+	return nil
+}
+
+// ConnString returns the DSN if one was configured, otherwise builds a
+// key/value connection string from the individual options. The statement
+// timeout is passed as a runtime parameter unless the DSN already sets one.
+func (c *PostgresConfig) ConnString() string {
+	dsn := c.DSN
+	if dsn == "" {
+		sslMode := c.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		dsn = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+			c.PostgresHost, c.PostgresPort, c.PostgresUser, c.PostgresPassword, c.DatabaseName, sslMode)
+	}
+	return c.withStatementTimeout(dsn)
+}
+
+// ReplicaConnStrings returns the replica DSNs with the statement timeout applied.
+func (c *PostgresConfig) ReplicaConnStrings() []string {
+	dsns := make([]string, len(c.ReplicaDSNs))
+	for i, dsn := range c.ReplicaDSNs {
+		dsns[i] = c.withStatementTimeout(dsn)
+	}
+	return dsns
+}
+
+func (c *PostgresConfig) withStatementTimeout(dsn string) string {
+	if c.StatementTimeout <= 0 || strings.Contains(dsn, "statement_timeout") {
+		return dsn
+	}
+	timeout := strconv.FormatInt(c.StatementTimeout.Milliseconds(), 10)
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		q := u.Query()
+		q.Set("statement_timeout", timeout)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " statement_timeout=" + timeout
+}
+
+var dsnPasswordRe = regexp.MustCompile(`(password=)(\S+)`)
+
+// Redacted returns a copy of the config that is safe to print.
+func (c *Config) Redacted() *Config {
+	pg := *c.Postgres
+	if pg.PostgresPassword != "" {
+		pg.PostgresPassword = redacted
+	}
+	pg.DSN = redactDSN(pg.DSN)
+	pg.ReplicaDSNs = make([]string, len(c.Postgres.ReplicaDSNs))
+	for i, dsn := range c.Postgres.ReplicaDSNs {
+		pg.ReplicaDSNs[i] = redactDSN(dsn)
+	}
+	st := *c.Storage
+	ca := *c.Cache
+	mo := *c.Moderation
+	fi := *c.Filter
+	id := *c.Idempotency
+	pq := *c.PersistedQueries
+	se := *c.Server
+	if se.GatewaySecret != "" {
+		se.GatewaySecret = redacted
+	}
+	return &Config{Server: &se, Postgres: &pg, Storage: &st, Cache: &ca, Moderation: &mo, Filter: &fi, Idempotency: &id, PersistedQueries: &pq}
+}
+
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return dsn
+	}
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		return u.String()
+	}
+	return dsnPasswordRe.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// Print writes the effective configuration as YAML with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(c.Redacted())
+}
+
+type flagValue struct {
+	ptr any
+	raw string
+	set bool
+}
+
+func (v *flagValue) String() string {
+	return v.raw
+}
+
+// IsBoolFlag lets boolean flags be passed bare, as -flag for -flag=true.
+func (v *flagValue) IsBoolFlag() bool {
+	_, ok := v.ptr.(*bool)
+	return ok
+}
+
+func (v *flagValue) Set(s string) error {
+	v.raw = s
+	v.set = true
+	return nil
+}
 
-	storageType, ok := os.LookupEnv("STORAGE_TYPE")
-	if !ok {
-		logger.Fatal("Can't read STORAGE_TYPE")
+func setValue(ptr any, s string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = s
+	case *int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*p = d
+	case *[]string:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*p = list
+	default:
+		return fmt.Errorf("unsupported config type %T", ptr)
 	}
-	return &StorageTypeConfig{StorageType: storageType}
+	return nil
 }
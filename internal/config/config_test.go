@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chdir moves the test into a directory without a .env file.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	chdir(t, t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("storage:\n  type: postgres\npostgres:\n  host: file-host\n  port: \"6000\"\n  db: filedb\n  user: fileuser\n"), 0o600)
+	require.NoError(t, err)
+
+	t.Setenv("STORAGE_TYPE", "postgres")
+	t.Setenv("POSTGRES_HOST", "env-host")
+
+	cfg, err := LoadConfig([]string{"-config", path, "-postgres-port", "7000"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "env-host", cfg.Postgres.PostgresHost)
+	assert.Equal(t, "7000", cfg.Postgres.PostgresPort)
+	assert.Equal(t, "filedb", cfg.Postgres.DatabaseName)
+	assert.Equal(t, "disable", cfg.Postgres.SSLMode)
+}
+
+func TestLoadConfig_MemoryNeedsNoPostgres(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv("STORAGE_TYPE", "memory")
+
+	cfg, err := LoadConfig(nil)
+	require.NoError(t, err)
+	assert.Equal(t, StorageMemory, cfg.Storage.StorageType)
+}
+
+func TestLoadConfig_BareBoolFlag(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	cfg, err := LoadConfig([]string{"-storage", "memory", "-persisted-queries-manifest", "ops.json", "-persisted-queries-strict"})
+	require.NoError(t, err)
+	assert.True(t, cfg.PersistedQueries.Strict)
+
+	cfg, err = LoadConfig([]string{"-storage", "memory", "-filter-normalize=false"})
+	require.NoError(t, err)
+	assert.False(t, cfg.Filter.Normalize)
+}
+
+func TestLoadConfig_PostgresValidation(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	_, err := LoadConfig([]string{"-storage", "postgres", "-postgres-host", ""})
+	assert.ErrorContains(t, err, "missing host")
+
+	_, err = LoadConfig([]string{"-storage", "postgres", "-postgres-dsn", "postgres://u:p@db/app?sslmode=require"})
+	assert.NoError(t, err)
+
+	_, err = LoadConfig([]string{"-storage", "sqlite"})
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_ProdNeedsGatewaySecret(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	_, err := LoadConfig([]string{"-profile", "prod"})
+	assert.ErrorContains(t, err, "gatewaySecret")
+
+	cfg, err := LoadConfig([]string{"-profile", "prod", "-gateway-secret", "s3cret"})
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.Server.GatewaySecret)
+}
+
+func TestLoadConfig_FilterActions(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	_, err := LoadConfig([]string{"-filter-banned-words-action", "drop"})
+	assert.ErrorContains(t, err, "bannedWordsAction")
+	_, err = LoadConfig([]string{"-filter-links-action", "mask"})
+	assert.ErrorContains(t, err, "linksAction")
+	_, err = LoadConfig([]string{"-filter-duplicate-action", "mask"})
+	assert.ErrorContains(t, err, "duplicateAction")
+
+	_, err = LoadConfig([]string{"-filter-banned-words-action", "Reject", "-filter-links-action", "reject"})
+	assert.NoError(t, err)
+}
+
+func TestPrint_RedactsSecrets(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Server.GatewaySecret = "hunter2"
+	cfg.Postgres.PostgresPassword = "hunter2"
+	cfg.Postgres.DSN = "postgres://user:hunter2@db:5432/app?sslmode=verify-full"
+
+	var sb strings.Builder
+	require.NoError(t, cfg.Print(&sb))
+
+	assert.NotContains(t, sb.String(), "hunter2")
+	assert.Contains(t, sb.String(), "sslmode=verify-full")
+	assert.Equal(t, "hunter2", cfg.Postgres.PostgresPassword)
+}
@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/requestid"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/google/uuid"
+)
+
+// auditTarget names what a write touched when neither the stored item nor
+// the result says so; an empty typ leaves the target type unset.
+type auditTarget struct {
+	typ, id string
+}
+
+// audit records a write in the audit log, including writes that fail, so
+// every API that goes through the gateways is audited alike. before is the
+// item as stored beforehand, or nil, and after is the write's result.
+// Recording errors are logged and never fail the write, which has already
+// been applied by then.
+func audit(ctx context.Context, store storage.Storage, action string, target auditTarget, before, after any, err error) {
+	entry := &models.AuditEntry{Action: action}
+	if user, ok := auth.UserFromContext(ctx); ok {
+		role := string(user.Role)
+		entry.Actor, entry.ActorRole = &user.ID, &role
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		entry.RequestID = &id
+	}
+	if err != nil {
+		msg := err.Error()
+		entry.Error = &msg
+	}
+	entry.TargetType, entry.TargetID = auditTargetOf(target, before, after)
+	beforeState := auditState(before)
+	entry.Before, entry.Diff = auditJSON(beforeState), []*models.FieldChange{}
+	// A failed write changed nothing, so it has no after state or diff.
+	if err == nil {
+		afterState := auditState(after)
+		entry.After, entry.Diff = auditJSON(afterState), auditDiff(beforeState, afterState)
+	}
+	entry.ID = uuid.New().String()
+	entry.At = time.Now().UTC()
+	if err := store.AppendAudit(ctx, entry); err != nil {
+		log.Printf("audit: %s: %v", action, err)
+	}
+}
+
+func auditTargetOf(fallback auditTarget, before, after any) (*string, *string) {
+	target := func(typ, id string) (*string, *string) {
+		return &typ, &id
+	}
+	for _, item := range []any{after, before} {
+		switch v := item.(type) {
+		case *models.Post:
+			if v != nil {
+				return target("POST", v.ID)
+			}
+		case *models.CommentResponse:
+			if v != nil {
+				return target("COMMENT", v.ID)
+			}
+		case *models.Report:
+			if v != nil {
+				return target(string(v.ItemType), v.ItemID)
+			}
+		case *models.Sanction:
+			if v != nil {
+				return target("USER", v.UserID)
+			}
+		}
+	}
+	if fallback.typ != "" {
+		return target(fallback.typ, fallback.id)
+	}
+	return nil, &fallback.id
+}
+
+// auditState flattens a result into its scalar fields; nested comments are
+// audited as their own items.
+func auditState(v any) map[string]any {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var state map[string]any
+	if json.Unmarshal(data, &state) != nil {
+		return nil
+	}
+	delete(state, "comments")
+	delete(state, "replies")
+	return state
+}
+
+func auditJSON(state map[string]any) *string {
+	if state == nil {
+		return nil
+	}
+	data, _ := json.Marshal(state)
+	s := string(data)
+	return &s
+}
+
+// auditDiff lists the fields that differ between two states, in name order.
+func auditDiff(before, after map[string]any) []*models.FieldChange {
+	fields := make(map[string]bool)
+	for k := range before {
+		fields[k] = true
+	}
+	for k := range after {
+		fields[k] = true
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	diff := []*models.FieldChange{}
+	for _, name := range names {
+		b, a := fieldJSON(before, name), fieldJSON(after, name)
+		if b == nil && a == nil || b != nil && a != nil && *b == *a {
+			continue
+		}
+		diff = append(diff, &models.FieldChange{Field: name, Before: b, After: a})
+	}
+	return diff
+}
+
+func fieldJSON(state map[string]any, name string) *string {
+	v, ok := state[name]
+	if !ok {
+		return nil
+	}
+	data, _ := json.Marshal(v)
+	s := string(data)
+	return &s
+}
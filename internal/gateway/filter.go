@@ -0,0 +1,163 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/NGerasimovvv/GraphQL/internal/config"
+)
+
+// Action is what a content filter wants done with a piece of text.
+// Stronger actions win when several filters disagree.
+type Action int
+
+const (
+	Allow Action = iota
+	// Mask means the filter has already rewritten the offending parts of the text.
+	Mask
+	// Flag lets the text through but puts it on the moderation queue.
+	Flag
+	Reject
+)
+
+func ParseAction(s string) (Action, error) {
+	switch strings.ToLower(s) {
+	case "mask":
+		return Mask, nil
+	case "flag":
+		return Flag, nil
+	case "reject":
+		return Reject, nil
+	default:
+		return Allow, fmt.Errorf("unknown filter action %q", s)
+	}
+}
+
+var ErrContentRejected = errors.New("content rejected")
+
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string        { return ErrContentRejected.Error() + ": " + e.Reason }
+func (e *RejectedError) Is(target error) bool { return target == ErrContentRejected }
+
+// Content is user text on its way to storage. Filters may rewrite Text.
+type Content struct {
+	Author string
+	Text   string
+	fold   folder
+	failed []func()
+}
+
+// folder is how a normaliser folds text. Implementations are comparable, so
+// filters can cache what they derive from one.
+type folder interface {
+	fold(s string) string
+}
+
+// OnFailed registers fn to run if the text is not saved after all, for
+// filters that remember what was posted.
+func (c *Content) OnFailed(fn func()) {
+	c.failed = append(c.failed, fn)
+}
+
+// Normalize folds s the way filters should compare text, so that look-alike
+// spellings match. Without a normaliser in the pipeline it only lowercases.
+func (c *Content) Normalize(s string) string {
+	if c.fold == nil {
+		return strings.ToLower(s)
+	}
+	return c.fold.fold(s)
+}
+
+type Verdict struct {
+	Action Action
+	Reason string
+}
+
+type ContentFilter interface {
+	Check(ctx context.Context, c *Content) (Verdict, error)
+}
+
+// Pipeline runs filters in order and stops at the first rejection.
+type Pipeline []ContentFilter
+
+// Outcome is the text to store and whether it must be reviewed by a
+// moderator. The caller reports a failed save with Failed.
+type Outcome struct {
+	Text    string
+	Flagged bool
+	failed  []func()
+}
+
+// Failed tells the filters that the text was not saved.
+func (o *Outcome) Failed() {
+	runAll(o.failed)
+}
+
+func runAll(fns []func()) {
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// Run passes text through the filters.
+func (p Pipeline) Run(ctx context.Context, author, text string) (*Outcome, error) {
+	c := &Content{Author: author, Text: text}
+	flagged := false
+	for _, f := range p {
+		v, err := f.Check(ctx, c)
+		if err != nil {
+			runAll(c.failed)
+			return nil, err
+		}
+		switch v.Action {
+		case Reject:
+			runAll(c.failed)
+			return nil, &RejectedError{Reason: v.Reason}
+		case Flag:
+			flagged = true
+		}
+	}
+	return &Outcome{Text: c.Text, Flagged: flagged, failed: c.failed}, nil
+}
+
+// NewPipeline builds the built-in filters enabled in cfg. The normaliser
+// runs first so every later filter matches against folded text.
+func NewPipeline(cfg *config.FilterConfig) (Pipeline, error) {
+	var p Pipeline
+	if cfg.Normalize {
+		p = append(p, ConfusableNormalizer{})
+	}
+	if len(cfg.BannedWords) > 0 {
+		action, err := ParseAction(cfg.BannedWordsAction)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, NewBannedWords(cfg.BannedWords, action))
+	}
+	if cfg.MaxLinks > 0 {
+		action, err := ParseAction(cfg.LinksAction)
+		if err != nil {
+			return nil, err
+		}
+		if action == Mask {
+			return nil, errors.New("links filter cannot mask")
+		}
+		p = append(p, LinkLimit{Max: cfg.MaxLinks, Action: action})
+	}
+	if cfg.DuplicateWindow > 0 {
+		action, err := ParseAction(cfg.DuplicateAction)
+		if err != nil {
+			return nil, err
+		}
+		if action == Mask {
+			return nil, errors.New("duplicate filter cannot mask")
+		}
+		p = append(p, NewDuplicateDetector(cfg.DuplicateWindow, action))
+	}
+	return p, nil
+}
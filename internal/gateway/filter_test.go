@@ -0,0 +1,162 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/config"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFoldConfusables(t *testing.T) {
+	tests := map[string]string{
+		"Spam":       "spam",
+		"ｓｐａｍ":       "spam",
+		"sрам":       "spam", // Cyrillic р, а, м
+		"s\u200bpam": "spam",
+		"spåm":       "spam",
+		"fr33 m0n3y": "free money",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, FoldConfusables(in), in)
+	}
+}
+
+func TestPipeline_BannedWords(t *testing.T) {
+	ctx := context.Background()
+	words := []string{"spam"}
+
+	masked := Pipeline{ConfusableNormalizer{}, NewBannedWords(words, Mask)}
+	out, err := masked.Run(ctx, "a", "no ｓｐａｍ here, spamming is fine")
+	require.NoError(t, err)
+	assert.False(t, out.Flagged)
+	assert.Equal(t, "no **** here, spamming is fine", out.Text)
+
+	rejected := Pipeline{ConfusableNormalizer{}, NewBannedWords(words, Reject)}
+	_, err = rejected.Run(ctx, "a", "s\u200bpam")
+	assert.ErrorIs(t, err, ErrContentRejected)
+
+	// Without the normaliser look-alikes slip through.
+	plain := Pipeline{NewBannedWords(words, Reject)}
+	_, err = plain.Run(ctx, "a", "sрам")
+	assert.NoError(t, err)
+
+	// A filter shared by pipelines folds its list once for each normaliser.
+	shared := NewBannedWords([]string{"fr33"}, Reject)
+	for i := 0; i < 2; i++ {
+		_, err = Pipeline{ConfusableNormalizer{}, shared}.Run(ctx, "a", "free")
+		assert.ErrorIs(t, err, ErrContentRejected)
+		_, err = Pipeline{shared}.Run(ctx, "a", "free")
+		assert.NoError(t, err)
+	}
+}
+
+func TestPipeline_LinksAndDuplicates(t *testing.T) {
+	ctx := context.Background()
+	dup := NewDuplicateDetector(time.Minute, Reject)
+	now := time.Unix(0, 0)
+	dup.now = func() time.Time { return now }
+	p := Pipeline{ConfusableNormalizer{}, LinkLimit{Max: 1, Action: Flag}, dup}
+
+	out, err := p.Run(ctx, "a", "see https://example.com")
+	require.NoError(t, err)
+	assert.False(t, out.Flagged)
+	// Text that failed to save is not a duplicate.
+	out.Failed()
+	_, err = p.Run(ctx, "a", "see https://example.com")
+	require.NoError(t, err)
+
+	out, err = p.Run(ctx, "a", "see https://a.example and www.b.example")
+	require.NoError(t, err)
+	assert.True(t, out.Flagged)
+
+	_, err = p.Run(ctx, "a", "SEE   https://example.com")
+	assert.ErrorIs(t, err, ErrContentRejected)
+	_, err = p.Run(ctx, "b", "see https://example.com")
+	assert.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	_, err = p.Run(ctx, "a", "see https://example.com")
+	assert.NoError(t, err)
+}
+
+func TestDuplicateDetector_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	p := Pipeline{NewDuplicateDetector(time.Minute, Reject)}
+
+	var wg sync.WaitGroup
+	var passed atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Run(ctx, "a", "hello"); err == nil {
+				passed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, passed.Load())
+}
+
+// A text rejected by a later filter does not count as posted.
+func TestDuplicateDetector_LaterReject(t *testing.T) {
+	ctx := context.Background()
+	dup := NewDuplicateDetector(time.Minute, Reject)
+	_, err := Pipeline{dup, NewBannedWords([]string{"spam"}, Reject)}.Run(ctx, "a", "spam")
+	require.ErrorIs(t, err, ErrContentRejected)
+	_, err = Pipeline{dup}.Run(ctx, "a", "spam")
+	assert.NoError(t, err)
+}
+
+func TestNewPipeline(t *testing.T) {
+	p, err := NewPipeline(&config.FilterConfig{Normalize: true, BannedWords: []string{"x"}, BannedWordsAction: "flag"})
+	require.NoError(t, err)
+	assert.Len(t, p, 2)
+
+	_, err = NewPipeline(&config.FilterConfig{MaxLinks: 1, LinksAction: "mask"})
+	assert.Error(t, err)
+	_, err = NewPipeline(&config.FilterConfig{DuplicateWindow: time.Second, DuplicateAction: "drop"})
+	assert.Error(t, err)
+}
+
+func TestCommentGateway_Filters(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "p1", "post", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+	g := NewCommentGateway(s, ConfusableNormalizer{}, NewBannedWords([]string{"spam"}, Mask), LinkLimit{Max: 0, Action: Flag})
+
+	ctx = auth.WithUser(ctx, &auth.User{ID: "a", Role: auth.RoleUser})
+	comment, err := g.CreateComment(ctx, "spam at www.example.com", "p1", "a")
+	require.NoError(t, err)
+	assert.Equal(t, "**** at www.example.com", comment.TextComment)
+	assert.Equal(t, models.StatusVisible, comment.Status)
+
+	queue, err := g.GetModerationQueue(ctx, 10, "")
+	require.NoError(t, err)
+	require.Len(t, queue.Comments, 1)
+	assert.Equal(t, comment.ID, queue.Comments[0].ID)
+}
+
+func TestCommentGateway_DuplicateAfterFailedCreate(t *testing.T) {
+	ctx := auth.WithUser(context.Background(), &auth.User{ID: "a", Role: auth.RoleUser})
+	s := storage.NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "p1", "post", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+	g := NewCommentGateway(s, NewDuplicateDetector(time.Minute, Reject))
+
+	_, err = g.CreateComment(ctx, "hello", "missing", "a")
+	require.ErrorContains(t, err, "not found")
+	_, err = g.CreateComment(ctx, "hello", "p1", "a")
+	require.NoError(t, err, "a comment that failed to save is not a duplicate")
+	_, err = g.CreateComment(ctx, "hello", "p1", "a")
+	assert.ErrorIs(t, err, ErrContentRejected)
+}
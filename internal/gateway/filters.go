@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ConfusableNormalizer makes later filters see through look-alike spellings:
+// compatibility forms (full-width letters, ligatures), accents, invisible
+// characters and Cyrillic or Greek letters that look like Latin ones.
+// It only changes how text is compared, never the stored text.
+type ConfusableNormalizer struct{}
+
+func (n ConfusableNormalizer) Check(ctx context.Context, c *Content) (Verdict, error) {
+	c.fold = n
+	return Verdict{Action: Allow}, nil
+}
+
+func (ConfusableNormalizer) fold(s string) string { return FoldConfusables(s) }
+
+var confusables = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's',
+	'α': 'a', 'β': 'b', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x',
+	'0': 'o', '1': 'l', '3': 'e', '$': 's', '@': 'a',
+}
+
+func FoldConfusables(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if folded, ok := confusables[r]; ok {
+			r = folded
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// BannedWords matches whole words against a list. With the Mask action the
+// matched words are replaced with asterisks.
+type BannedWords struct {
+	words  []string
+	action Action
+
+	mu sync.Mutex
+	// banned holds the words as folded by each normaliser seen so far, so
+	// the list is only folded once per pipeline rather than per comment.
+	banned map[folder]map[string]bool
+}
+
+func NewBannedWords(words []string, action Action) *BannedWords {
+	return &BannedWords{words: words, action: action, banned: make(map[folder]map[string]bool)}
+}
+
+func (f *BannedWords) folded(c *Content) map[string]bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	banned, ok := f.banned[c.fold]
+	if !ok {
+		banned = make(map[string]bool, len(f.words))
+		for _, w := range f.words {
+			banned[c.Normalize(w)] = true
+		}
+		f.banned[c.fold] = banned
+	}
+	return banned
+}
+
+func (f *BannedWords) Check(ctx context.Context, c *Content) (Verdict, error) {
+	banned := f.folded(c)
+
+	found := false
+	var b strings.Builder
+	forEachWord(c.Text, func(word string, isWord bool) {
+		if isWord && banned[c.Normalize(word)] {
+			found = true
+			if f.action == Mask {
+				word = strings.Repeat("*", len([]rune(word)))
+			}
+		}
+		b.WriteString(word)
+	})
+	if !found {
+		return Verdict{Action: Allow}, nil
+	}
+	if f.action == Mask {
+		c.Text = b.String()
+	}
+	return Verdict{Action: f.action, Reason: "banned word"}, nil
+}
+
+// forEachWord splits s into alternating runs of word and non-word
+// characters. Invisible format characters count as part of a word so they
+// cannot be used to break a banned word apart.
+func forEachWord(s string, fn func(part string, isWord bool)) {
+	start, startIsWord := 0, false
+	for i, r := range s {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r)
+		if i == 0 {
+			startIsWord = isWord
+		} else if isWord != startIsWord {
+			fn(s[start:i], startIsWord)
+			start, startIsWord = i, isWord
+		}
+	}
+	if start < len(s) {
+		fn(s[start:], startIsWord)
+	}
+}
+
+var linkRe = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+
+// LinkLimit acts on text with more than Max links.
+type LinkLimit struct {
+	Max    int
+	Action Action
+}
+
+func (f LinkLimit) Check(ctx context.Context, c *Content) (Verdict, error) {
+	if len(linkRe.FindAllStringIndex(c.Normalize(c.Text), -1)) > f.Max {
+		return Verdict{Action: f.Action, Reason: "too many links"}, nil
+	}
+	return Verdict{Action: Allow}, nil
+}
+
+// DuplicateDetector acts when an author repeats the same text within the
+// window. Text counts as posted as soon as it passes, so two identical
+// comments sent at once cannot both get through; one that then fails to
+// save is forgotten and can be sent again.
+type DuplicateDetector struct {
+	window time.Duration
+	action Action
+	now    func() time.Time
+
+	mu sync.Mutex
+	// seen maps author and text to when they were last posted; order lists
+	// the same entries oldest first so expired ones are dropped cheaply.
+	seen  map[string]time.Time
+	order []seenText
+}
+
+type seenText struct {
+	key string
+	at  time.Time
+}
+
+func NewDuplicateDetector(window time.Duration, action Action) *DuplicateDetector {
+	return &DuplicateDetector{window: window, action: action, now: time.Now, seen: make(map[string]time.Time)}
+}
+
+func (f *DuplicateDetector) Check(ctx context.Context, c *Content) (Verdict, error) {
+	key := c.Author + "\x00" + strings.Join(strings.Fields(c.Normalize(c.Text)), " ")
+	now := f.now()
+
+	f.mu.Lock()
+	for len(f.order) > 0 && now.Sub(f.order[0].at) >= f.window {
+		if old := f.order[0]; f.seen[old.key].Equal(old.at) {
+			delete(f.seen, old.key)
+		}
+		f.order = f.order[1:]
+	}
+	if _, dup := f.seen[key]; dup {
+		f.mu.Unlock()
+		return Verdict{Action: f.action, Reason: "duplicate message"}, nil
+	}
+	f.seen[key] = now
+	f.order = append(f.order, seenText{key: key, at: now})
+	f.mu.Unlock()
+	c.OnFailed(func() { f.forget(key, now) })
+	return Verdict{Action: Allow}, nil
+}
+
+// forget drops the entry recorded at at for text that was not saved. Its
+// place in order is left to expire.
+func (f *DuplicateDetector) forget(key string, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.seen[key].Equal(at) {
+		delete(f.seen, key)
+	}
+}
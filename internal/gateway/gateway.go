@@ -2,7 +2,11 @@ package gateway
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"time"
 
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
 	"github.com/NGerasimovvv/GraphQL/internal/models"
 	"github.com/NGerasimovvv/GraphQL/internal/storage"
 )
@@ -10,17 +14,29 @@ import (
 type CommentGateway interface {
 	GetAllComments(ctx context.Context, limit, offset *int) ([]*models.CommentResponse, error)
 	GetCommentByID(ctx context.Context, id string) (*models.CommentResponse, error)
+	GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.CommentResponse, error)
 	CreateComment(ctx context.Context, commentText, itemId, user string) (*models.CommentResponse, error)
+	UpdateComment(ctx context.Context, id, textComment string, expectedVersion int) (*models.CommentResponse, error)
+	SetCommentStatus(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error)
+	GetRevisions(ctx context.Context, id string) ([]*models.Revision, error)
+	GetModerationQueue(ctx context.Context, first int, after string) (*models.ModerationQueue, error)
+	ReviewComment(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error)
 	GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*models.CommentResponse, error)
+	GetCommentsByPostIDs(ctx context.Context, postIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error)
 	GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*models.CommentResponse, error)
+	GetCommentsByParentIDs(ctx context.Context, parentIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error)
+	GetCommentTree(ctx context.Context, postID string, maxDepth, perLevelLimit int) ([]*models.CommentResponse, error)
 }
 
 type commentGateway struct {
 	storage storage.Storage
+	filters Pipeline
 }
 
-func NewCommentGateway(storage storage.Storage) CommentGateway {
-	return &commentGateway{storage: storage}
+// NewCommentGateway returns a gateway that passes comment text through
+// filters before it is stored.
+func NewCommentGateway(storage storage.Storage, filters ...ContentFilter) CommentGateway {
+	return &commentGateway{storage: storage, filters: filters}
 }
 
 func (s *commentGateway) GetAllComments(ctx context.Context, limit, offset *int) ([]*models.CommentResponse, error) {
@@ -31,22 +47,116 @@ func (s *commentGateway) GetCommentByID(ctx context.Context, id string) (*models
 	return s.storage.GetCommentByID(ctx, id)
 }
 
+func (s *commentGateway) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.CommentResponse, error) {
+	return s.storage.GetCommentsByIDs(ctx, ids)
+}
+
 func (s *commentGateway) CreateComment(ctx context.Context, commentText, itemId, user string) (*models.CommentResponse, error) {
-	return s.storage.CreateComment(ctx, commentText, itemId, user)
+	if err := checkAuthor(ctx, user); err != nil {
+		audit(ctx, s.storage, "createComment", auditTarget{id: itemId}, nil, nil, err)
+		return nil, err
+	}
+	out, err := s.filters.Run(ctx, user, commentText)
+	if err != nil {
+		audit(ctx, s.storage, "createComment", auditTarget{id: itemId}, nil, nil, err)
+		return nil, err
+	}
+	comment, err := s.storage.CreateComment(ctx, out.Text, itemId, user)
+	if err != nil {
+		out.Failed()
+	} else {
+		err = s.queueIf(ctx, out.Flagged, comment.ID)
+	}
+	audit(ctx, s.storage, "createComment", auditTarget{id: itemId}, nil, comment, err)
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (s *commentGateway) UpdateComment(ctx context.Context, id, textComment string, expectedVersion int) (*models.CommentResponse, error) {
+	before, err := s.storage.GetCommentByID(ctx, id)
+	if err != nil {
+		audit(ctx, s.storage, "updateComment", auditTarget{id: id}, nil, nil, err)
+		return nil, err
+	}
+	out, err := s.filters.Run(ctx, before.AuthorComment, textComment)
+	var comment *models.CommentResponse
+	if err == nil {
+		if comment, err = s.storage.UpdateComment(ctx, id, out.Text, expectedVersion); err != nil {
+			out.Failed()
+		} else {
+			err = s.queueIf(ctx, out.Flagged, comment.ID)
+		}
+	}
+	audit(ctx, s.storage, "updateComment", auditTarget{id: id}, before, comment, err)
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (s *commentGateway) queueIf(ctx context.Context, flagged bool, id string) error {
+	if !flagged {
+		return nil
+	}
+	return s.storage.QueueComment(ctx, id)
+}
+
+func (s *commentGateway) SetCommentStatus(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	before, _ := s.storage.GetCommentByID(ctx, id)
+	comment, err := s.storage.SetCommentStatus(ctx, id, status)
+	audit(ctx, s.storage, "setCommentStatus", auditTarget{id: id}, before, comment, err)
+	return comment, err
+}
+
+func (s *commentGateway) GetRevisions(ctx context.Context, id string) ([]*models.Revision, error) {
+	return s.storage.GetRevisions(ctx, id)
 }
 
 func (s *commentGateway) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*models.CommentResponse, error) {
 	return s.storage.GetCommentsByPostID(ctx, postID, limit, offset)
 }
 
+func (s *commentGateway) GetCommentsByPostIDs(ctx context.Context, postIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error) {
+	return s.storage.GetCommentsByPostIDs(ctx, postIDs, limit, offset)
+}
+
 func (s *commentGateway) GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*models.CommentResponse, error) {
 	return s.storage.GetCommentsByParentID(ctx, parentID, limit, offset)
 }
 
+func (s *commentGateway) GetCommentsByParentIDs(ctx context.Context, parentIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error) {
+	return s.storage.GetCommentsByParentIDs(ctx, parentIDs, limit, offset)
+}
+
+func (s *commentGateway) GetCommentTree(ctx context.Context, postID string, maxDepth, perLevelLimit int) ([]*models.CommentResponse, error) {
+	return s.storage.GetCommentTree(ctx, postID, maxDepth, perLevelLimit)
+}
+
+func (s *commentGateway) GetModerationQueue(ctx context.Context, first int, after string) (*models.ModerationQueue, error) {
+	return s.storage.GetModerationQueue(ctx, first, after)
+}
+
+func (s *commentGateway) ReviewComment(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	action := "approveComment"
+	if status != models.StatusVisible {
+		action = "rejectComment"
+	}
+	before, _ := s.storage.GetCommentByID(ctx, id)
+	comment, err := s.storage.ReviewComment(ctx, id, status)
+	audit(ctx, s.storage, action, auditTarget{id: id}, before, comment, err)
+	return comment, err
+}
+
 type PostGateway interface {
-	CreatePost(ctx context.Context, id, text string, commentable bool, authorPost string) (*models.Post, error)
+	CreatePost(ctx context.Context, id, text string, commentable bool, authorPost string, moderation models.Moderation) (*models.Post, error)
 	GetPostByID(ctx context.Context, id string) (*models.Post, error)
+	GetPostsByIDs(ctx context.Context, ids []string) (map[string]*models.Post, error)
 	GetAllPosts(ctx context.Context, limit, offset *int) ([]*models.Post, error)
+	UpdatePost(ctx context.Context, id, textPost string, expectedVersion int) (*models.Post, error)
+	SetPostStatus(ctx context.Context, id string, status models.Status) (*models.Post, error)
+	GetRevisions(ctx context.Context, id string) ([]*models.Revision, error)
 }
 
 type postGateway struct {
@@ -57,14 +167,209 @@ func NewPostGateway(storage storage.Storage) PostGateway {
 	return &postGateway{storage: storage}
 }
 
-func (s *postGateway) CreatePost(ctx context.Context, id, textPost string, commentable bool, authorPost string) (*models.Post, error) {
-	return s.storage.CreatePost(ctx, id, textPost, commentable, authorPost)
+func (s *postGateway) CreatePost(ctx context.Context, id, textPost string, commentable bool, authorPost string, moderation models.Moderation) (*models.Post, error) {
+	var post *models.Post
+	err := checkAuthor(ctx, authorPost)
+	if err == nil {
+		post, err = s.storage.CreatePost(ctx, id, textPost, commentable, authorPost, moderation)
+	}
+	audit(ctx, s.storage, "createPost", auditTarget{"POST", id}, nil, post, err)
+	return post, err
 }
 
 func (s *postGateway) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
 	return s.storage.GetPostByID(ctx, id)
 }
 
+func (s *postGateway) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*models.Post, error) {
+	return s.storage.GetPostsByIDs(ctx, ids)
+}
+
 func (s *postGateway) GetAllPosts(ctx context.Context, limit, offset *int) ([]*models.Post, error) {
 	return s.storage.GetAllPosts(ctx, limit, offset)
 }
+
+func (s *postGateway) UpdatePost(ctx context.Context, id, textPost string, expectedVersion int) (*models.Post, error) {
+	before, _ := s.storage.GetPostByID(ctx, id)
+	post, err := s.storage.UpdatePost(ctx, id, textPost, expectedVersion)
+	audit(ctx, s.storage, "updatePost", auditTarget{id: id}, before, post, err)
+	return post, err
+}
+
+func (s *postGateway) SetPostStatus(ctx context.Context, id string, status models.Status) (*models.Post, error) {
+	before, _ := s.storage.GetPostByID(ctx, id)
+	post, err := s.storage.SetPostStatus(ctx, id, status)
+	audit(ctx, s.storage, "setPostStatus", auditTarget{id: id}, before, post, err)
+	return post, err
+}
+
+func (s *postGateway) GetRevisions(ctx context.Context, id string) ([]*models.Revision, error) {
+	return s.storage.GetRevisions(ctx, id)
+}
+
+// checkAuthor binds new posts and comments to the signed-in caller: the
+// author is who ownership checks compare against, so nobody may write under
+// another user's name. Storage then applies the author's bans and mutes.
+func checkAuthor(ctx context.Context, author string) error {
+	user, err := auth.RequireRole(ctx, auth.RoleUser)
+	if err != nil {
+		return err
+	}
+	if author != user.ID {
+		return fmt.Errorf("%w: author must be the signed-in user", auth.ErrForbidden)
+	}
+	return nil
+}
+
+type ReportGateway interface {
+	Report(ctx context.Context, itemID, reporter string, reason models.ReportReason, note *string) (*models.Report, error)
+	GetReports(ctx context.Context, limit, offset *int) ([]*models.ReportGroup, error)
+}
+
+type reportGateway struct {
+	storage   storage.Storage
+	threshold int
+}
+
+// NewReportGateway returns a gateway that hides a visible item once
+// threshold distinct users have reported it. A zero threshold never hides.
+func NewReportGateway(storage storage.Storage, threshold int) ReportGateway {
+	return &reportGateway{storage: storage, threshold: threshold}
+}
+
+func (s *reportGateway) Report(ctx context.Context, itemID, reporter string, reason models.ReportReason, note *string) (*models.Report, error) {
+	report, reporters, err := s.storage.CreateReport(ctx, itemID, reporter, reason, note)
+	if err == nil && s.threshold > 0 && reporters >= s.threshold {
+		err = s.hide(ctx, report)
+	}
+	audit(ctx, s.storage, "report", auditTarget{id: itemID}, nil, report, err)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// hide only touches visible items, so it never undoes a moderator's decision.
+func (s *reportGateway) hide(ctx context.Context, report *models.Report) error {
+	if report.ItemType == models.ItemTypePost {
+		post, err := s.storage.GetPostByID(ctx, report.ItemID)
+		if err != nil || post.Status != models.StatusVisible {
+			return err
+		}
+		_, err = s.storage.SetPostStatus(ctx, report.ItemID, models.StatusHidden)
+		return err
+	}
+	comment, err := s.storage.GetCommentByID(ctx, report.ItemID)
+	if err != nil || comment.Status != models.StatusVisible {
+		return err
+	}
+	_, err = s.storage.SetCommentStatus(ctx, report.ItemID, models.StatusHidden)
+	return err
+}
+
+func (s *reportGateway) GetReports(ctx context.Context, limit, offset *int) ([]*models.ReportGroup, error) {
+	return s.storage.GetReports(ctx, limit, offset)
+}
+
+type SanctionGateway interface {
+	BanUser(ctx context.Context, userID string, kind models.SanctionKind, duration time.Duration, reason *string, moderator string) (*models.Sanction, error)
+	UnbanUser(ctx context.Context, userID string) (bool, error)
+	BlockUser(ctx context.Context, postID, userID string) error
+	UnblockUser(ctx context.Context, postID, userID string) (bool, error)
+}
+
+type sanctionGateway struct {
+	storage storage.Storage
+}
+
+func NewSanctionGateway(storage storage.Storage) SanctionGateway {
+	return &sanctionGateway{storage: storage}
+}
+
+// BanUser sanctions userID for duration, or permanently when duration is 0.
+func (s *sanctionGateway) BanUser(ctx context.Context, userID string, kind models.SanctionKind, duration time.Duration, reason *string, moderator string) (*models.Sanction, error) {
+	now := time.Now()
+	sanction := &models.Sanction{UserID: userID, Kind: kind, Reason: reason, CreatedBy: moderator, CreatedAt: now}
+	if duration > 0 {
+		expiresAt := now.Add(duration)
+		sanction.ExpiresAt = &expiresAt
+	}
+	stored, err := s.storage.BanUser(ctx, sanction)
+	audit(ctx, s.storage, "banUser", auditTarget{"USER", userID}, nil, stored, err)
+	return stored, err
+}
+
+func (s *sanctionGateway) UnbanUser(ctx context.Context, userID string) (bool, error) {
+	removed, err := s.storage.UnbanUser(ctx, userID)
+	audit(ctx, s.storage, "unbanUser", auditTarget{"USER", userID}, nil, nil, err)
+	return removed, err
+}
+
+// postBlock is the audited state of a block, which names the post it
+// applies under as well as the blocked user.
+type postBlock struct {
+	PostID string `json:"postId"`
+	UserID string `json:"userId"`
+}
+
+func (s *sanctionGateway) BlockUser(ctx context.Context, postID, userID string) error {
+	err := s.storage.BlockUser(ctx, postID, userID)
+	audit(ctx, s.storage, "blockUser", auditTarget{"USER", userID}, nil, &postBlock{postID, userID}, err)
+	return err
+}
+
+func (s *sanctionGateway) UnblockUser(ctx context.Context, postID, userID string) (bool, error) {
+	removed, err := s.storage.UnblockUser(ctx, postID, userID)
+	var before any
+	if removed {
+		before = &postBlock{postID, userID}
+	}
+	audit(ctx, s.storage, "unblockUser", auditTarget{"USER", userID}, before, nil, err)
+	return removed, err
+}
+
+type AuditGateway interface {
+	GetAuditLog(ctx context.Context, filter *models.AuditFilter, first int, after string) (*models.AuditLogPage, error)
+}
+
+type auditGateway struct {
+	storage storage.Storage
+}
+
+func NewAuditGateway(storage storage.Storage) AuditGateway {
+	return &auditGateway{storage: storage}
+}
+
+func (s *auditGateway) GetAuditLog(ctx context.Context, filter *models.AuditFilter, first int, after string) (*models.AuditLogPage, error) {
+	return s.storage.GetAuditLog(ctx, filter, first, after)
+}
+
+// SweepSanctions deletes expired bans and mutes every interval until ctx is
+// done. Expired sanctions are never enforced, so this only reclaims space.
+func SweepSanctions(ctx context.Context, storage storage.Storage, interval time.Duration) {
+	sweep(ctx, "sanctions", interval, storage.SweepSanctions)
+}
+
+// SweepIdempotencyKeys deletes expired idempotency keys every interval until
+// ctx is done.
+func SweepIdempotencyKeys(ctx context.Context, storage storage.Storage, interval time.Duration) {
+	sweep(ctx, "idempotency keys", interval, storage.SweepIdempotencyKeys)
+}
+
+func sweep(ctx context.Context, name string, interval time.Duration, fn func(context.Context, time.Time) (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := fn(ctx, now)
+			if err != nil {
+				log.Printf("%s: sweep: %v", name, err)
+			} else if n > 0 {
+				log.Printf("%s: swept %d expired", name, n)
+			}
+		}
+	}
+}
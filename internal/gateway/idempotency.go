@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+)
+
+// idempotencyClaimTimeout bounds how long a key stays claimed by a request
+// that never finished, for example because the server stopped.
+const idempotencyClaimTimeout = 30 * time.Second
+
+type IdempotencyGateway struct {
+	storage storage.Storage
+	window  time.Duration
+}
+
+// NewIdempotencyGateway returns a gateway that replays results of keyed
+// requests for window. A zero window disables it.
+func NewIdempotencyGateway(storage storage.Storage, window time.Duration) *IdempotencyGateway {
+	return &IdempotencyGateway{storage: storage, window: window}
+}
+
+// Idempotent runs create at most once per key within the window and returns
+// its stored result for repeated keys. A repeated key with a different
+// fingerprint of the arguments is an error. Failed requests release the key,
+// so they can be retried. Without a key or gateway, create just runs.
+func Idempotent[T any](ctx context.Context, g *IdempotencyGateway, key, fingerprint string, create func() (T, error)) (T, error) {
+	var result T
+	if key == "" || g == nil || g.window <= 0 {
+		return create()
+	}
+	held, err := g.storage.ClaimIdempotencyKey(ctx, &storage.IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   time.Now().Add(min(g.window, idempotencyClaimTimeout)),
+	})
+	if err != nil {
+		return result, err
+	}
+	if held != nil {
+		switch {
+		case held.Fingerprint != fingerprint:
+			return result, storage.ErrIdempotencyKeyReused
+		case held.Result == nil:
+			return result, storage.ErrIdempotencyInProgress
+		}
+		err := json.Unmarshal(held.Result, &result)
+		return result, err
+	}
+
+	result, err = create()
+	if err != nil {
+		if err := g.storage.ReleaseIdempotencyKey(ctx, key); err != nil {
+			log.Printf("idempotency: release: %v", err)
+		}
+		return result, err
+	}
+	data, err := json.Marshal(result)
+	if err == nil {
+		err = g.storage.CompleteIdempotencyKey(ctx, key, data, time.Now().Add(g.window))
+	}
+	if err != nil {
+		// The request succeeded; a retry now creates a duplicate, which is
+		// no worse than having no key at all.
+		log.Printf("idempotency: complete: %v", err)
+		if err := g.storage.ReleaseIdempotencyKey(ctx, key); err != nil {
+			log.Printf("idempotency: release: %v", err)
+		}
+	}
+	return result, nil
+}
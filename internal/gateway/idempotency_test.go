@@ -0,0 +1,45 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotent(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	g := NewIdempotencyGateway(s, time.Hour)
+
+	calls := 0
+	create := func() (int, error) {
+		calls++
+		return calls, nil
+	}
+	n, err := Idempotent(ctx, g, "k", "fp", create)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = Idempotent(ctx, g, "k", "fp", create)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = Idempotent(ctx, g, "", "fp", create)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// A concurrent request holding the key has not stored a result yet.
+	_, err = s.ClaimIdempotencyKey(ctx, &storage.IdempotencyRecord{Key: "busy", Fingerprint: "fp", ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	_, err = Idempotent(ctx, g, "busy", "fp", create)
+	assert.ErrorIs(t, err, storage.ErrIdempotencyInProgress)
+
+	swept, err := s.SweepIdempotencyKeys(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, swept)
+	n, err = Idempotent(ctx, g, "k", "fp", create)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
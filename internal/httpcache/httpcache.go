@@ -0,0 +1,147 @@
+// Package httpcache makes GET responses cacheable by browsers and CDNs: it
+// adds ETags, answers If-None-Match with 304 and sets Cache-Control from the
+// max age the handler allowed for the response.
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// Policy collects the max age of a response. It starts unrestricted; every
+// Restrict can only lower it.
+type Policy struct {
+	mu     sync.Mutex
+	set    bool
+	maxAge int
+}
+
+type policyKey struct{}
+
+func WithPolicy(ctx context.Context, p *Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+// Restrict lowers the max age of the response being built in ctx to at
+// most maxAge seconds. It does nothing outside a cached request.
+func Restrict(ctx context.Context, maxAge int) {
+	p, ok := ctx.Value(policyKey{}).(*Policy)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.set || maxAge < p.maxAge {
+		p.set, p.maxAge = true, maxAge
+	}
+}
+
+// MaxAge returns the max age allowed so far, 0 if nothing set one.
+func (p *Policy) MaxAge() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.maxAge
+}
+
+// ETag returns the strong entity tag of body.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Middleware buffers the response of the handlers after it, tags it with an
+// ETag and a Cache-Control header and replaces it with 304 Not Modified when
+// the client already has it. GraphQL responses with errors are not cached,
+// and responses for signed-in users are cached privately.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := &Policy{}
+		c.Request = c.Request.WithContext(WithPolicy(c.Request.Context(), policy))
+		w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		body := w.body.Bytes()
+		if w.status != http.StatusOK {
+			c.Writer.WriteHeader(w.status)
+			_, _ = c.Writer.Write(body)
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Add("Vary", auth.HeaderUserID+", "+auth.HeaderRole)
+		maxAge := policy.MaxAge()
+		if maxAge <= 0 || hasErrors(body) {
+			header.Set("Cache-Control", "no-store")
+		} else {
+			scope := "public"
+			if _, ok := auth.UserFromContext(c.Request.Context()); ok {
+				scope = "private"
+			}
+			header.Set("Cache-Control", scope+", max-age="+strconv.Itoa(maxAge))
+		}
+
+		etag := ETag(body)
+		header.Set("ETag", etag)
+		if matches(c.GetHeader("If-None-Match"), etag) {
+			header.Del("Content-Type")
+			c.Writer.WriteHeader(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return
+		}
+		c.Writer.WriteHeader(http.StatusOK)
+		_, _ = c.Writer.Write(body)
+	}
+}
+
+// matches reports whether an If-None-Match header lists etag, ignoring the
+// weak prefix as RFC 9110 requires for this comparison.
+func matches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func hasErrors(body []byte) bool {
+	var resp struct {
+		Errors json.RawMessage `json:"errors"`
+	}
+	return json.Unmarshal(body, &resp) != nil || len(resp.Errors) > 0
+}
+
+// bufferedWriter holds the response back until the middleware decides
+// between the body and 304.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) { w.status = status }
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) { return w.body.Write(data) }
+
+func (w *bufferedWriter) WriteString(s string) (int, error) { return w.body.WriteString(s) }
+
+func (w *bufferedWriter) Status() int { return w.status }
+
+func (w *bufferedWriter) Size() int { return w.body.Len() }
+
+func (w *bufferedWriter) Written() bool { return w.body.Len() > 0 }
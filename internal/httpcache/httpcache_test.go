@@ -0,0 +1,79 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRouter(body string, maxAges ...int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/graphql", auth.Middleware(""), Middleware(), func(c *gin.Context) {
+		for _, maxAge := range maxAges {
+			Restrict(c.Request.Context(), maxAge)
+		}
+		c.Data(http.StatusOK, "application/json", []byte(body))
+	})
+	return r
+}
+
+func get(r http.Handler, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/graphql", nil)
+	for k, v := range header {
+		req.Header.Set(k, v[0])
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_ETag(t *testing.T) {
+	const body = `{"data":{"posts":[]}}`
+	r := newRouter(body, 60, 30)
+
+	rec := get(r, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, body, rec.Body.String())
+	assert.Equal(t, "public, max-age=30", rec.Header().Get("Cache-Control"))
+	etag := rec.Header().Get("ETag")
+	assert.Equal(t, ETag([]byte(body)), etag)
+
+	rec = get(r, http.Header{"If-None-Match": {`"other", W/` + etag}})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+	rec = get(r, http.Header{"If-None-Match": {`"other"`}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, body, rec.Body.String())
+}
+
+func TestMiddleware_CacheControl(t *testing.T) {
+	user := http.Header{auth.HeaderUserID: {"alice"}, auth.HeaderRole: {"user"}}
+	tests := []struct {
+		name    string
+		body    string
+		maxAges []int
+		header  http.Header
+		want    string
+	}{
+		{"hinted", `{"data":{}}`, []int{60}, nil, "public, max-age=60"},
+		{"signed in", `{"data":{}}`, []int{60}, user, "private, max-age=60"},
+		{"unhinted field", `{"data":{}}`, []int{60, 0}, nil, "no-store"},
+		{"no hints", `{"data":{}}`, nil, nil, "no-store"},
+		{"errors", `{"errors":[{"message":"post not found"}],"data":null}`, []int{60}, nil, "no-store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(newRouter(tt.body, tt.maxAges...), tt.header)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.want, rec.Header().Get("Cache-Control"))
+			assert.Contains(t, rec.Header().Get("Vary"), auth.HeaderUserID)
+		})
+	}
+}
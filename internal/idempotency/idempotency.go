@@ -0,0 +1,56 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+const Header = "Idempotency-Key"
+
+type contextKey struct{}
+
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFromContext returns the Idempotency-Key of the request, or "".
+func KeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(contextKey{}).(string)
+	return key
+}
+
+// Scope ties a client's key to the action and the signed-in caller, so
+// callers cannot replay each other's results. The GraphQL and REST APIs
+// share it, so a request retried through either one is replayed. Anonymous
+// requests get no key.
+func Scope(ctx context.Context, action, key string) string {
+	user, ok := auth.UserFromContext(ctx)
+	if key == "" || !ok {
+		return ""
+	}
+	return strings.Join([]string{action, user.ID, key}, "\x00")
+}
+
+// Middleware puts the Idempotency-Key header into the request context.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader(Header); key != "" {
+			c.Request = c.Request.WithContext(WithKey(c.Request.Context(), key))
+		}
+		c.Next()
+	}
+}
+
+// Fingerprint identifies the arguments of a keyed request. The GraphQL and
+// REST APIs share one key space, so both must fingerprint the same way.
+func Fingerprint(args ...any) string {
+	data, _ := json.Marshal(args)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
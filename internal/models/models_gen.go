@@ -2,6 +2,56 @@
 
 package models
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// An object with a global ID that node(id:) can refetch. ID arguments accept
+// global IDs as well as the raw IDs used before.
+type Node interface {
+	IsNode()
+	GetID() string
+}
+
+// One mutation as it was executed, with the state of its target before and
+// after as JSON.
+type AuditEntry struct {
+	ID string    `json:"id"`
+	At time.Time `json:"at"`
+	// Null for anonymous callers.
+	Actor      *string `json:"actor,omitempty"`
+	ActorRole  *string `json:"actorRole,omitempty"`
+	Action     string  `json:"action"`
+	TargetType *string `json:"targetType,omitempty"`
+	// A global ID when the target is a post or comment.
+	TargetID  *string        `json:"targetId,omitempty"`
+	RequestID *string        `json:"requestId,omitempty"`
+	Before    *string        `json:"before,omitempty"`
+	After     *string        `json:"after,omitempty"`
+	Diff      []*FieldChange `json:"diff"`
+	// Set when the mutation failed.
+	Error *string `json:"error,omitempty"`
+}
+
+type AuditFilter struct {
+	Actor  *string `json:"actor,omitempty"`
+	Action *string `json:"action,omitempty"`
+	// Accepts global IDs as well as raw IDs.
+	TargetID *string    `json:"targetId,omitempty"`
+	Since    *time.Time `json:"since,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`
+}
+
+// A page of the audit log, newest entries first.
+type AuditLogPage struct {
+	Entries     []*AuditEntry `json:"entries"`
+	EndCursor   *string       `json:"endCursor,omitempty"`
+	HasNextPage bool          `json:"hasNextPage"`
+}
+
 type Comment struct {
 	ID            string `json:"id"`
 	TextComment   string `json:"textComment"`
@@ -16,18 +66,455 @@ type CommentResponse struct {
 	ParentCommentID *string            `json:"parentCommentID,omitempty"`
 	AuthorComment   string             `json:"authorComment"`
 	Replies         []*CommentResponse `json:"replies"`
+	Status          Status             `json:"status"`
+	Edited          bool               `json:"edited"`
+	Revisions       []*Revision        `json:"revisions"`
+	Version         int                `json:"version"`
+}
+
+func (CommentResponse) IsNode()            {}
+func (this CommentResponse) GetID() string { return this.ID }
+
+func (CommentResponse) IsEntity() {}
+
+type CommentResponseByIDsInput struct {
+	ID string `json:"ID"`
+}
+
+type DiffChunk struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+type FieldChange struct {
+	Field  string  `json:"field"`
+	Before *string `json:"before,omitempty"`
+	After  *string `json:"after,omitempty"`
+}
+
+type ModerationQueue struct {
+	Comments    []*CommentResponse `json:"comments"`
+	EndCursor   *string            `json:"endCursor,omitempty"`
+	HasNextPage bool               `json:"hasNextPage"`
 }
 
 type Mutation struct {
 }
 
+// Posts are federation entities other subgraphs can reference by global ID.
 type Post struct {
 	ID          string             `json:"id"`
 	TextPost    string             `json:"textPost"`
 	AuthorPost  string             `json:"authorPost"`
 	Comments    []*CommentResponse `json:"comments"`
 	Commentable bool               `json:"commentable"`
+	Moderation  Moderation         `json:"moderation"`
+	Status      Status             `json:"status"`
+	// Whether the text was changed after the post was created.
+	Edited bool `json:"edited"`
+	// Every version of the text, oldest first.
+	Revisions []*Revision `json:"revisions"`
+	// Incremented by every change; pass it back as expectedVersion to update.
+	Version int `json:"version"`
+}
+
+func (Post) IsNode()            {}
+func (this Post) GetID() string { return this.ID }
+
+func (Post) IsEntity() {}
+
+type PostByIDsInput struct {
+	ID string `json:"ID"`
 }
 
 type Query struct {
 }
+
+type Report struct {
+	ID        string       `json:"id"`
+	ItemID    string       `json:"itemId"`
+	ItemType  ItemType     `json:"itemType"`
+	Reporter  string       `json:"reporter"`
+	Reason    ReportReason `json:"reason"`
+	Note      *string      `json:"note,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// All reports filed against one post or comment.
+type ReportGroup struct {
+	ItemID   string    `json:"itemId"`
+	ItemType ItemType  `json:"itemType"`
+	Count    int       `json:"count"`
+	Reports  []*Report `json:"reports"`
+}
+
+type Revision struct {
+	// Revisions are numbered from 1, the text as first published.
+	Number    int       `json:"number"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type RevisionDiff struct {
+	ItemID string       `json:"itemId"`
+	From   int          `json:"from"`
+	To     int          `json:"to"`
+	Chunks []*DiffChunk `json:"chunks"`
+}
+
+type Sanction struct {
+	UserID    string       `json:"userId"`
+	Kind      SanctionKind `json:"kind"`
+	Reason    *string      `json:"reason,omitempty"`
+	CreatedBy string       `json:"createdBy"`
+	CreatedAt time.Time    `json:"createdAt"`
+	// Null for a permanent sanction.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type DiffMode string
+
+const (
+	DiffModeLine DiffMode = "LINE"
+	DiffModeWord DiffMode = "WORD"
+)
+
+var AllDiffMode = []DiffMode{
+	DiffModeLine,
+	DiffModeWord,
+}
+
+func (e DiffMode) IsValid() bool {
+	switch e {
+	case DiffModeLine, DiffModeWord:
+		return true
+	}
+	return false
+}
+
+func (e DiffMode) String() string {
+	return string(e)
+}
+
+func (e *DiffMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DiffMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DiffMode", str)
+	}
+	return nil
+}
+
+func (e DiffMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type DiffOp string
+
+const (
+	DiffOpEqual  DiffOp = "EQUAL"
+	DiffOpInsert DiffOp = "INSERT"
+	DiffOpDelete DiffOp = "DELETE"
+)
+
+var AllDiffOp = []DiffOp{
+	DiffOpEqual,
+	DiffOpInsert,
+	DiffOpDelete,
+}
+
+func (e DiffOp) IsValid() bool {
+	switch e {
+	case DiffOpEqual, DiffOpInsert, DiffOpDelete:
+		return true
+	}
+	return false
+}
+
+func (e DiffOp) String() string {
+	return string(e)
+}
+
+func (e *DiffOp) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DiffOp(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DiffOp", str)
+	}
+	return nil
+}
+
+func (e DiffOp) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ItemType string
+
+const (
+	ItemTypePost    ItemType = "POST"
+	ItemTypeComment ItemType = "COMMENT"
+)
+
+var AllItemType = []ItemType{
+	ItemTypePost,
+	ItemTypeComment,
+}
+
+func (e ItemType) IsValid() bool {
+	switch e {
+	case ItemTypePost, ItemTypeComment:
+		return true
+	}
+	return false
+}
+
+func (e ItemType) String() string {
+	return string(e)
+}
+
+func (e *ItemType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ItemType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ItemType", str)
+	}
+	return nil
+}
+
+func (e ItemType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// How comments under a post are moderated: not at all, before they become
+// visible (PRE) or after they were published (POST).
+type Moderation string
+
+const (
+	ModerationNone Moderation = "NONE"
+	ModerationPre  Moderation = "PRE"
+	ModerationPost Moderation = "POST"
+)
+
+var AllModeration = []Moderation{
+	ModerationNone,
+	ModerationPre,
+	ModerationPost,
+}
+
+func (e Moderation) IsValid() bool {
+	switch e {
+	case ModerationNone, ModerationPre, ModerationPost:
+		return true
+	}
+	return false
+}
+
+func (e Moderation) String() string {
+	return string(e)
+}
+
+func (e *Moderation) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Moderation(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Moderation", str)
+	}
+	return nil
+}
+
+func (e Moderation) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportReason string
+
+const (
+	ReportReasonSpam     ReportReason = "SPAM"
+	ReportReasonAbuse    ReportReason = "ABUSE"
+	ReportReasonOffTopic ReportReason = "OFF_TOPIC"
+	ReportReasonOther    ReportReason = "OTHER"
+)
+
+var AllReportReason = []ReportReason{
+	ReportReasonSpam,
+	ReportReasonAbuse,
+	ReportReasonOffTopic,
+	ReportReasonOther,
+}
+
+func (e ReportReason) IsValid() bool {
+	switch e {
+	case ReportReasonSpam, ReportReasonAbuse, ReportReasonOffTopic, ReportReasonOther:
+		return true
+	}
+	return false
+}
+
+func (e ReportReason) String() string {
+	return string(e)
+}
+
+func (e *ReportReason) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportReason(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportReason", str)
+	}
+	return nil
+}
+
+func (e ReportReason) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleUser      Role = "USER"
+	RoleModerator Role = "MODERATOR"
+	RoleAdmin     Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RoleUser,
+	RoleModerator,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// BAN stops a user from posting and commenting, MUTE only from commenting.
+type SanctionKind string
+
+const (
+	SanctionKindBan  SanctionKind = "BAN"
+	SanctionKindMute SanctionKind = "MUTE"
+)
+
+var AllSanctionKind = []SanctionKind{
+	SanctionKindBan,
+	SanctionKindMute,
+}
+
+func (e SanctionKind) IsValid() bool {
+	switch e {
+	case SanctionKindBan, SanctionKindMute:
+		return true
+	}
+	return false
+}
+
+func (e SanctionKind) String() string {
+	return string(e)
+}
+
+func (e *SanctionKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SanctionKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SanctionKind", str)
+	}
+	return nil
+}
+
+func (e SanctionKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Status string
+
+const (
+	StatusVisible  Status = "VISIBLE"
+	StatusHidden   Status = "HIDDEN"
+	StatusDeleted  Status = "DELETED"
+	StatusPending  Status = "PENDING"
+	StatusRejected Status = "REJECTED"
+)
+
+var AllStatus = []Status{
+	StatusVisible,
+	StatusHidden,
+	StatusDeleted,
+	StatusPending,
+	StatusRejected,
+}
+
+func (e Status) IsValid() bool {
+	switch e {
+	case StatusVisible, StatusHidden, StatusDeleted, StatusPending, StatusRejected:
+		return true
+	}
+	return false
+}
+
+func (e Status) String() string {
+	return string(e)
+}
+
+func (e *Status) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Status(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Status", str)
+	}
+	return nil
+}
+
+func (e Status) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
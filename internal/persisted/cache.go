@@ -0,0 +1,53 @@
+// Package persisted serves automatic persisted queries from the shared cache
+// and restricts the API to an allowlist of operations from a manifest.
+package persisted
+
+import (
+	"context"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/cache"
+)
+
+// Cache stores automatic persisted queries, keyed by their sha256 hash, in
+// a cache.Cache. It implements graphql.Cache for the APQ extension.
+type Cache struct {
+	cache  cache.Cache
+	ttl    time.Duration
+	pinned map[string]string
+}
+
+func NewCache(c cache.Cache, ttl time.Duration) *Cache {
+	return &Cache{cache: c, ttl: ttl}
+}
+
+// Pin serves the operations of m by their manifest IDs for the lifetime of
+// c; unlike registered queries they are never evicted and never expire.
+// Pin must be called before c is used.
+func (c *Cache) Pin(m *Manifest) {
+	if c.pinned == nil {
+		c.pinned = make(map[string]string, len(m.Operations))
+	}
+	for _, op := range m.Operations {
+		c.pinned[op.ID] = op.Body
+	}
+}
+
+func (c *Cache) Get(ctx context.Context, hash string) (interface{}, bool) {
+	if query, ok := c.pinned[hash]; ok {
+		return query, true
+	}
+	query, ok, err := c.cache.Get(ctx, "apq:"+hash)
+	if err != nil || !ok {
+		return nil, false
+	}
+	return string(query), true
+}
+
+func (c *Cache) Add(ctx context.Context, hash string, query interface{}) {
+	if _, ok := c.pinned[hash]; ok {
+		return
+	}
+	// A cache that is down only costs the client a resend of the query.
+	_ = c.cache.Set(ctx, "apq:"+hash, []byte(query.(string)), c.ttl)
+}
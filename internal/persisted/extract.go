@@ -0,0 +1,187 @@
+package persisted
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+	// Registers the standard validation rules.
+	_ "github.com/vektah/gqlparser/v2/validator/rules"
+)
+
+var (
+	// templateRe matches gql`...` and graphql`...` tagged templates.
+	templateRe = regexp.MustCompile("(?s)\\b(?:gql|graphql)\\s*(?:\\(\\s*)?`([^`]*)`")
+	// interpolationRe matches ${fragment} interpolations, which only pull in
+	// fragments defined elsewhere in the client code.
+	interpolationRe = regexp.MustCompile(`\$\{[^}]*\}`)
+)
+
+// ExtractFiles walks paths for client code and extracts its operations:
+// whole .graphql and .gql files, and gql/graphql tagged templates in
+// JavaScript and TypeScript files. node_modules and hidden directories are
+// skipped.
+func ExtractFiles(schema *ast.Schema, paths []string) (*Manifest, error) {
+	var sources []*ast.Source
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && (d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			found, err := readSources(path)
+			sources = append(sources, found...)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return Extract(schema, sources)
+}
+
+var scriptExts = map[string]bool{".js": true, ".jsx": true, ".mjs": true, ".cjs": true, ".ts": true, ".tsx": true}
+
+func readSources(path string) ([]*ast.Source, error) {
+	ext := filepath.Ext(path)
+	document := ext == ".graphql" || ext == ".gql"
+	if !document && !scriptExts[ext] {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if document {
+		return []*ast.Source{{Name: path, Input: string(data)}}, nil
+	}
+	var sources []*ast.Source
+	for _, m := range templateRe.FindAllStringSubmatch(string(data), -1) {
+		sources = append(sources, &ast.Source{Name: path, Input: interpolationRe.ReplaceAllString(m[1], "")})
+	}
+	return sources, nil
+}
+
+// Extract builds a manifest of the named operations in sources. Each
+// operation is printed in canonical form together with the fragments it
+// uses, from any source, and validated against schema.
+func Extract(schema *ast.Schema, sources []*ast.Source) (*Manifest, error) {
+	fragments := map[string]*ast.FragmentDefinition{}
+	operations := map[string]*ast.OperationDefinition{}
+	for _, src := range sources {
+		doc, err := parser.ParseQuery(src)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range doc.Fragments {
+			if prev, ok := fragments[f.Name]; ok {
+				return nil, fmt.Errorf("fragment %s is defined in %s and %s", f.Name, prev.Position.Src.Name, src.Name)
+			}
+			fragments[f.Name] = f
+		}
+		for _, op := range doc.Operations {
+			if op.Name == "" {
+				return nil, fmt.Errorf("%s:%d: operations must be named", src.Name, op.Position.Line)
+			}
+			if prev, ok := operations[op.Name]; ok {
+				return nil, fmt.Errorf("operation %s is defined in %s and %s", op.Name, prev.Position.Src.Name, src.Name)
+			}
+			operations[op.Name] = op
+		}
+	}
+
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := &Manifest{Format: ManifestFormat, Version: 1, Operations: []Operation{}}
+	for _, name := range names {
+		op := operations[name]
+		used := map[string]*ast.FragmentDefinition{}
+		if err := collectFragments(op.SelectionSet, fragments, used); err != nil {
+			return nil, fmt.Errorf("operation %s: %w", name, err)
+		}
+		doc := &ast.QueryDocument{Operations: ast.OperationList{op}, Fragments: sortedFragments(used)}
+		if errs := validator.Validate(schema, doc); len(errs) > 0 {
+			return nil, fmt.Errorf("operation %s: %w", name, errs)
+		}
+
+		body := format(doc)
+		m.Operations = append(m.Operations, Operation{ID: Hash(body), Name: name, Type: string(op.Operation), Body: body})
+	}
+	return m, nil
+}
+
+// Normalize prints a client's query document the way Extract prints
+// manifest bodies, so an operation hashes to its manifest ID however the
+// client formats it.
+func Normalize(query string) (string, error) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return "", err
+	}
+	fragments := make(map[string]*ast.FragmentDefinition, len(doc.Fragments))
+	for _, f := range doc.Fragments {
+		fragments[f.Name] = f
+	}
+	return format(&ast.QueryDocument{Operations: doc.Operations, Fragments: sortedFragments(fragments)}), nil
+}
+
+func format(doc *ast.QueryDocument) string {
+	var buf bytes.Buffer
+	formatter.NewFormatter(&buf, formatter.WithIndent("  ")).FormatQueryDocument(doc)
+	return buf.String()
+}
+
+func collectFragments(set ast.SelectionSet, fragments, used map[string]*ast.FragmentDefinition) error {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if err := collectFragments(sel.SelectionSet, fragments, used); err != nil {
+				return err
+			}
+		case *ast.InlineFragment:
+			if err := collectFragments(sel.SelectionSet, fragments, used); err != nil {
+				return err
+			}
+		case *ast.FragmentSpread:
+			if _, ok := used[sel.Name]; ok {
+				continue
+			}
+			f, ok := fragments[sel.Name]
+			if !ok {
+				return fmt.Errorf("unknown fragment %s", sel.Name)
+			}
+			used[sel.Name] = f
+			if err := collectFragments(f.SelectionSet, fragments, used); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sortedFragments(used map[string]*ast.FragmentDefinition) []*ast.FragmentDefinition {
+	list := make([]*ast.FragmentDefinition, 0, len(used))
+	for _, f := range used {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,69 @@
+package persisted
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const testSchema = `
+type Query { posts(limit: Int): [Post!]! }
+type Mutation { createPost(text: String!): Post! }
+type Post { id: ID! text: String! }
+`
+
+func loadTestSchema(t *testing.T) *ast.Schema {
+	t.Helper()
+	schema, err := gqlparser.LoadSchema(&ast.Source{Name: "schema.graphql", Input: testSchema})
+	require.NoError(t, err)
+	return schema
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+}
+
+func TestExtractFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "src", "posts.ts"), "import { gql } from '@apollo/client';\n"+
+		"const FIELDS = gql`fragment PostFields on Post { id text }`;\n"+
+		"export const POSTS = gql`\n  query Posts($limit: Int) { posts(limit: $limit) { ...PostFields } }\n  ${FIELDS}\n`;\n")
+	writeFile(t, filepath.Join(dir, "src", "create.graphql"), "mutation CreatePost($text: String!) { createPost(text: $text) { id } }\n")
+	writeFile(t, filepath.Join(dir, "node_modules", "lib", "index.js"), "gql`query Vendored { nope }`")
+
+	m, err := ExtractFiles(loadTestSchema(t), []string{dir})
+	require.NoError(t, err)
+	assert.Equal(t, ManifestFormat, m.Format)
+	require.Len(t, m.Operations, 2)
+
+	create, posts := m.Operations[0], m.Operations[1]
+	assert.Equal(t, "CreatePost", create.Name)
+	assert.Equal(t, "mutation", create.Type)
+	assert.Equal(t, "Posts", posts.Name)
+	assert.Equal(t, "query", posts.Type)
+	assert.Equal(t, "query Posts ($limit: Int) {\n  posts(limit: $limit) {\n    ... PostFields\n  }\n}\nfragment PostFields on Post {\n  id\n  text\n}\n", posts.Body)
+	assert.Equal(t, Hash(posts.Body), posts.ID)
+}
+
+func TestExtract_Errors(t *testing.T) {
+	schema := loadTestSchema(t)
+	tests := map[string]string{
+		"anonymous":        "{ posts { id } }",
+		"unknown field":    "query Posts { posts { title } }",
+		"unknown fragment": "query Posts { posts { ...Missing } }",
+		"duplicate name":   "query Posts { posts { id } } query Posts { posts { text } }",
+	}
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Extract(schema, []*ast.Source{{Name: "client.graphql", Input: query}})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package persisted
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ManifestFormat identifies the Apollo persisted query manifest format, so
+// manifests can be shared with Apollo tooling.
+const ManifestFormat = "apollo-persisted-query-manifest"
+
+// ErrNotAllowed is the code of operations rejected in strict mode.
+const ErrNotAllowed = "OPERATION_NOT_ALLOWED"
+
+type Manifest struct {
+	Format     string      `json:"format"`
+	Version    int         `json:"version"`
+	Operations []Operation `json:"operations"`
+}
+
+// Operation is one allowed operation. ID is the sha256 of Body, the
+// operation in canonical form. Clients that send only a hash must send the
+// ID from the manifest, not a hash of their own query text.
+type Operation struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Body string `json:"body"`
+}
+
+// Hash returns the persisted query hash of query.
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadManifest reads a manifest written by Extract and checks that every
+// operation ID matches its body.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if m.Format != ManifestFormat || m.Version != 1 {
+		return nil, fmt.Errorf("%s: unsupported manifest format %q version %d", path, m.Format, m.Version)
+	}
+	for _, op := range m.Operations {
+		if op.ID != Hash(op.Body) {
+			return nil, fmt.Errorf("%s: operation %s: id does not match the body", path, op.Name)
+		}
+	}
+	return &m, nil
+}
+
+// Allowlist is a gqlgen extension that executes only the operations of a
+// manifest. Clients send either the full query text, in any formatting, or,
+// as with APQ, just the manifest ID in the persistedQuery extension.
+type Allowlist struct {
+	queries map[string]string
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationParameterMutator
+} = (*Allowlist)(nil)
+
+func NewAllowlist(m *Manifest) *Allowlist {
+	queries := make(map[string]string, len(m.Operations))
+	for _, op := range m.Operations {
+		queries[op.ID] = op.Body
+	}
+	return &Allowlist{queries: queries}
+}
+
+func (a *Allowlist) ExtensionName() string { return "OperationAllowlist" }
+
+func (a *Allowlist) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (a *Allowlist) MutateOperationParameters(ctx context.Context, params *graphql.RawParams) *gqlerror.Error {
+	var sent string
+	if ext := params.Extensions["persistedQuery"]; ext != nil {
+		m, ok := ext.(map[string]interface{})
+		if !ok {
+			return gqlerror.Errorf("invalid persistedQuery extension")
+		}
+		sent, _ = m["sha256Hash"].(string)
+	}
+
+	hash := sent
+	if params.Query != "" {
+		hash = Hash(params.Query)
+		if sent != "" && sent != hash {
+			return gqlerror.Errorf("provided APQ hash does not match query")
+		}
+		if _, ok := a.queries[hash]; !ok {
+			if body, err := Normalize(params.Query); err == nil {
+				hash = Hash(body)
+			}
+		}
+	}
+	query, ok := a.queries[hash]
+	if !ok {
+		err := gqlerror.Errorf("operation is not in the allowlist")
+		errcode.Set(err, ErrNotAllowed)
+		return err
+	}
+	params.Query = query
+	return nil
+}
@@ -0,0 +1,97 @@
+package persisted
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/NGerasimovvv/GraphQL/internal/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const postsQuery = "query Posts {\n  posts {\n    id\n  }\n}\n"
+
+func writeManifest(t *testing.T, m *Manifest) string {
+	t.Helper()
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	writeFile(t, path, string(data))
+	return path
+}
+
+func TestLoadManifest(t *testing.T) {
+	op := Operation{ID: Hash(postsQuery), Name: "Posts", Type: "query", Body: postsQuery}
+	m, err := LoadManifest(writeManifest(t, &Manifest{Format: ManifestFormat, Version: 1, Operations: []Operation{op}}))
+	require.NoError(t, err)
+	assert.Equal(t, []Operation{op}, m.Operations)
+
+	op.Body = "query Posts { posts { text } }"
+	_, err = LoadManifest(writeManifest(t, &Manifest{Format: ManifestFormat, Version: 1, Operations: []Operation{op}}))
+	assert.ErrorContains(t, err, "does not match")
+
+	_, err = LoadManifest(writeManifest(t, &Manifest{Format: "other", Version: 1}))
+	assert.ErrorContains(t, err, "unsupported manifest format")
+}
+
+func TestAllowlist(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllowlist(&Manifest{Operations: []Operation{{ID: Hash(postsQuery), Name: "Posts", Body: postsQuery}}})
+	persisted := func(hash string) map[string]interface{} {
+		return map[string]interface{}{"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash}}
+	}
+
+	params := &graphql.RawParams{Query: postsQuery}
+	assert.Nil(t, a.MutateOperationParameters(ctx, params))
+
+	// The client's own formatting matches the canonical body.
+	params = &graphql.RawParams{Query: "query Posts { posts { id } }"}
+	require.Nil(t, a.MutateOperationParameters(ctx, params))
+	assert.Equal(t, postsQuery, params.Query)
+
+	params = &graphql.RawParams{Extensions: persisted(Hash(postsQuery))}
+	require.Nil(t, a.MutateOperationParameters(ctx, params))
+	assert.Equal(t, postsQuery, params.Query)
+
+	err := a.MutateOperationParameters(ctx, &graphql.RawParams{Query: "{ posts { id } }"})
+	require.NotNil(t, err)
+	assert.Equal(t, ErrNotAllowed, err.Extensions["code"])
+
+	err = a.MutateOperationParameters(ctx, &graphql.RawParams{Extensions: persisted(Hash("{ posts { id } }"))})
+	require.NotNil(t, err)
+	assert.Equal(t, ErrNotAllowed, err.Extensions["code"])
+
+	err = a.MutateOperationParameters(ctx, &graphql.RawParams{Query: postsQuery, Extensions: persisted(Hash("other"))})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Message, "does not match")
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(cache.NewLRU(10, time.Minute), time.Minute)
+	_, ok := c.Get(ctx, Hash(postsQuery))
+	assert.False(t, ok)
+	c.Add(ctx, Hash(postsQuery), postsQuery)
+	query, ok := c.Get(ctx, Hash(postsQuery))
+	require.True(t, ok)
+	assert.Equal(t, postsQuery, query)
+}
+
+func TestCache_Pin(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(cache.NewLRU(1, time.Minute), time.Minute)
+	c.Pin(&Manifest{Operations: []Operation{{ID: Hash(postsQuery), Name: "Posts", Body: postsQuery}}})
+
+	// Registered queries fill the LRU without evicting the manifest.
+	c.Add(ctx, Hash("{ a }"), "{ a }")
+	c.Add(ctx, Hash("{ b }"), "{ b }")
+	query, ok := c.Get(ctx, Hash(postsQuery))
+	require.True(t, ok)
+	assert.Equal(t, postsQuery, query)
+	_, ok = c.Get(ctx, Hash("{ a }"))
+	assert.False(t, ok)
+}
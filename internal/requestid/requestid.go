@@ -0,0 +1,49 @@
+package requestid
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID in ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Middleware keeps a well-formed X-Request-ID sent by the client or a proxy
+// and generates one otherwise. The ID is echoed in the response.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if !valid(id) {
+			id = uuid.New().String()
+		}
+		c.Header(Header, id)
+		c.Request = c.Request.WithContext(With(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// valid accepts up to 64 printable ASCII characters without spaces.
+func valid(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
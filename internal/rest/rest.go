@@ -0,0 +1,401 @@
+// Package rest serves posts and comments as a versioned JSON API for clients
+// that do not speak GraphQL. It goes through the same gateways as the GraphQL
+// API, so content filters, sanctions, idempotency and visibility rules apply
+// to both alike.
+package rest
+
+import (
+	_ "embed"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/gateway"
+	"github.com/NGerasimovvv/GraphQL/internal/idempotency"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/requestid"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/NGerasimovvv/GraphQL/internal/visibility"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Spec is the OpenAPI 3 description of the API.
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+type API struct {
+	Posts       gateway.PostGateway
+	Comments    gateway.CommentGateway
+	Idempotency *gateway.IdempotencyGateway
+	// VerboseErrors reports database and network failures to clients as
+	// they are instead of as INTERNAL.
+	VerboseErrors bool
+
+	base string
+}
+
+// Register adds the API routes and the spec, at openapi.yaml, to rg.
+func (a *API) Register(rg *gin.RouterGroup) {
+	a.base = rg.BasePath()
+	rg.GET("/openapi.yaml", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/yaml", Spec)
+	})
+	rg.GET("/posts", a.listPosts)
+	rg.POST("/posts", a.createPost)
+	rg.GET("/posts/:id", a.getPost)
+	rg.GET("/posts/:id/comments", a.listComments)
+	rg.POST("/posts/:id/comments", a.createComment)
+	rg.GET("/comments/:id", a.getComment)
+	rg.GET("/comments/:id/replies", a.listReplies)
+	rg.POST("/comments/:id/replies", a.createReply)
+}
+
+// Post is the JSON representation of a post. Comments are served separately.
+type Post struct {
+	ID          string            `json:"id"`
+	Text        string            `json:"text"`
+	Author      string            `json:"author"`
+	Commentable bool              `json:"commentable"`
+	Moderation  models.Moderation `json:"moderation"`
+	Status      models.Status     `json:"status"`
+	Edited      bool              `json:"edited"`
+	Version     int               `json:"version"`
+}
+
+// Comment is the JSON representation of a comment. Replies are served
+// separately.
+type Comment struct {
+	ID              string        `json:"id"`
+	PostID          string        `json:"postId"`
+	ParentCommentID *string       `json:"parentCommentId"`
+	Text            string        `json:"text"`
+	Author          string        `json:"author"`
+	Status          models.Status `json:"status"`
+	Edited          bool          `json:"edited"`
+	Version         int           `json:"version"`
+}
+
+// Error is the body of every error response.
+type Error struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+func newPost(p *models.Post) Post {
+	return Post{
+		ID:          p.ID,
+		Text:        p.TextPost,
+		Author:      p.AuthorPost,
+		Commentable: p.Commentable,
+		Moderation:  p.Moderation,
+		Status:      p.Status,
+		Edited:      p.Edited,
+		Version:     p.Version,
+	}
+}
+
+func newComment(c *models.CommentResponse) Comment {
+	return Comment{
+		ID:              c.ID,
+		PostID:          c.PostID,
+		ParentCommentID: c.ParentCommentID,
+		Text:            c.TextComment,
+		Author:          c.AuthorComment,
+		Status:          c.Status,
+		Edited:          c.Edited,
+		Version:         c.Version,
+	}
+}
+
+func newComments(comments []*models.CommentResponse) []Comment {
+	out := make([]Comment, 0, len(comments))
+	for _, c := range comments {
+		out = append(out, newComment(c))
+	}
+	return out
+}
+
+func (a *API) listPosts(c *gin.Context) {
+	limit, offset, ok := paging(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	posts, err := a.Posts.GetAllPosts(ctx, limit, offset)
+	if err != nil {
+		a.fail(c, err)
+		return
+	}
+	posts = visibility.Posts(ctx, posts)
+	out := make([]Post, 0, len(posts))
+	for _, p := range posts {
+		out = append(out, newPost(p))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (a *API) getPost(c *gin.Context) {
+	post, ok := a.post(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, newPost(post))
+}
+
+func (a *API) getComment(c *gin.Context) {
+	comment, ok := a.comment(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, newComment(comment))
+}
+
+// listComments serves every comment of a post, replies included, in the
+// order they were written; parentCommentId threads them.
+func (a *API) listComments(c *gin.Context) {
+	limit, offset, ok := paging(c)
+	if !ok {
+		return
+	}
+	post, ok := a.post(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	comments, err := a.Comments.GetCommentsByPostID(ctx, post.ID, limit, offset)
+	if err != nil {
+		a.fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newComments(visibility.Comments(ctx, comments)))
+}
+
+func (a *API) listReplies(c *gin.Context) {
+	limit, offset, ok := paging(c)
+	if !ok {
+		return
+	}
+	comment, ok := a.comment(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	replies, err := a.Comments.GetCommentsByParentID(ctx, comment.ID, limit, offset)
+	if err != nil {
+		a.fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newComments(visibility.Comments(ctx, replies)))
+}
+
+type createPostRequest struct {
+	Text        string            `json:"text" binding:"required"`
+	Author      string            `json:"author" binding:"required"`
+	Commentable *bool             `json:"commentable" binding:"required"`
+	Moderation  models.Moderation `json:"moderation"`
+}
+
+func (a *API) createPost(c *gin.Context) {
+	var req createPostRequest
+	if !bind(c, &req) {
+		return
+	}
+	if req.Moderation == "" {
+		req.Moderation = models.ModerationNone
+	}
+	if !req.Moderation.IsValid() {
+		abort(c, http.StatusBadRequest, "BAD_REQUEST", "moderation must be one of NONE, PRE, POST")
+		return
+	}
+	ctx := c.Request.Context()
+	key := idempotency.Scope(ctx, "createPost", idempotency.KeyFromContext(ctx))
+	post, err := gateway.Idempotent(ctx, a.Idempotency, key, idempotency.Fingerprint(req.Text, *req.Commentable, req.Moderation), func() (*models.Post, error) {
+		return a.Posts.CreatePost(ctx, uuid.New().String(), req.Text, *req.Commentable, req.Author, req.Moderation)
+	})
+	if err != nil {
+		a.fail(c, err)
+		return
+	}
+	c.Header("Location", a.base+"/posts/"+post.ID)
+	c.JSON(http.StatusCreated, newPost(post))
+}
+
+type createCommentRequest struct {
+	Text   string `json:"text" binding:"required"`
+	Author string `json:"author" binding:"required"`
+}
+
+func (a *API) createComment(c *gin.Context) {
+	post, ok := a.post(c)
+	if !ok {
+		return
+	}
+	a.reply(c, post.ID)
+}
+
+func (a *API) createReply(c *gin.Context) {
+	comment, ok := a.comment(c)
+	if !ok {
+		return
+	}
+	a.reply(c, comment.ID)
+}
+
+// reply creates a comment under the post or comment itemID.
+func (a *API) reply(c *gin.Context, itemID string) {
+	var req createCommentRequest
+	if !bind(c, &req) {
+		return
+	}
+	ctx := c.Request.Context()
+	key := idempotency.Scope(ctx, "createComment", idempotency.KeyFromContext(ctx))
+	comment, err := gateway.Idempotent(ctx, a.Idempotency, key, idempotency.Fingerprint(req.Text, itemID), func() (*models.CommentResponse, error) {
+		return a.Comments.CreateComment(ctx, req.Text, itemID, req.Author)
+	})
+	if err != nil {
+		a.fail(c, err)
+		return
+	}
+	c.Header("Location", a.base+"/comments/"+comment.ID)
+	c.JSON(http.StatusCreated, newComment(comment))
+}
+
+// post looks up the post of the :id parameter as the caller sees it, and
+// responds with 404 if there is none.
+func (a *API) post(c *gin.Context) (*models.Post, bool) {
+	ctx := c.Request.Context()
+	id, ok := itemID(c)
+	if !ok {
+		abort(c, http.StatusNotFound, "NOT_FOUND", "post not found")
+		return nil, false
+	}
+	posts, err := a.Posts.GetPostsByIDs(ctx, []string{id})
+	if err != nil {
+		a.fail(c, err)
+		return nil, false
+	}
+	post, ok := posts[id]
+	if ok {
+		post = visibility.Post(ctx, post)
+	}
+	if post == nil {
+		abort(c, http.StatusNotFound, "NOT_FOUND", "post not found")
+		return nil, false
+	}
+	return post, true
+}
+
+// comment looks up the comment of the :id parameter as the caller sees it,
+// and responds with 404 if there is none.
+func (a *API) comment(c *gin.Context) (*models.CommentResponse, bool) {
+	ctx := c.Request.Context()
+	id, ok := itemID(c)
+	if !ok {
+		abort(c, http.StatusNotFound, "NOT_FOUND", "comment not found")
+		return nil, false
+	}
+	comments, err := a.Comments.GetCommentsByIDs(ctx, []string{id})
+	if err != nil {
+		a.fail(c, err)
+		return nil, false
+	}
+	comment, ok := comments[id]
+	if ok {
+		comment = visibility.Comment(ctx, comment)
+	}
+	if comment == nil {
+		abort(c, http.StatusNotFound, "NOT_FOUND", "comment not found")
+		return nil, false
+	}
+	return comment, true
+}
+
+// itemID returns the :id parameter. Posts and comments are created with
+// UUIDs, so anything else names no item; it is not passed on to storage,
+// which may reject it as malformed rather than report it missing.
+func itemID(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	_, err := uuid.Parse(id)
+	return id, err == nil
+}
+
+// errorStatuses map gateway errors to a status and the code GraphQL clients
+// get for them in the "code" extension.
+var errorStatuses = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{auth.ErrUnauthenticated, http.StatusUnauthorized, "UNAUTHENTICATED"},
+	{auth.ErrForbidden, http.StatusForbidden, "FORBIDDEN"},
+	{storage.ErrBanned, http.StatusForbidden, "BANNED"},
+	{storage.ErrBlocked, http.StatusForbidden, "BLOCKED"},
+	{storage.ErrIdempotencyInProgress, http.StatusConflict, "IN_PROGRESS"},
+	{storage.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED"},
+	{storage.ErrConflict, http.StatusConflict, "CONFLICT"},
+	{storage.ErrNotCommentable, http.StatusConflict, "NOT_COMMENTABLE"},
+	{gateway.ErrContentRejected, http.StatusUnprocessableEntity, "CONTENT_REJECTED"},
+}
+
+// fail responds with the status for err. Other errors are outcomes of the
+// request and answered with 400, except for database and network failures,
+// which are 500 and, unless verbose, logged and reported only as INTERNAL.
+func (a *API) fail(c *gin.Context, err error) {
+	for _, e := range errorStatuses {
+		if errors.Is(err, e.err) {
+			abort(c, e.status, e.code, err.Error())
+			return
+		}
+	}
+	if storage.IsInternal(err) {
+		msg := err.Error()
+		if !a.VerboseErrors {
+			log.Printf("request %s: %v", requestid.FromContext(c.Request.Context()), err)
+			msg = "internal server error"
+		}
+		abort(c, http.StatusInternalServerError, "INTERNAL", msg)
+		return
+	}
+	abort(c, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+}
+
+func abort(c *gin.Context, status int, code, msg string) {
+	c.AbortWithStatusJSON(status, Error{Error: msg, Code: code})
+}
+
+// bind decodes the JSON body into req, responding with 400 if it is invalid.
+func bind(c *gin.Context, req any) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		abort(c, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return false
+	}
+	return true
+}
+
+// paging reads the limit and offset query parameters, responding with 400 if
+// they are not non-negative integers.
+func paging(c *gin.Context) (limit, offset *int, ok bool) {
+	parse := func(name string) (*int, bool) {
+		s, set := c.GetQuery(name)
+		if !set {
+			return nil, true
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			abort(c, http.StatusBadRequest, "BAD_REQUEST", name+" must be a non-negative integer")
+			return nil, false
+		}
+		return &n, true
+	}
+	if limit, ok = parse("limit"); !ok {
+		return nil, nil, false
+	}
+	if offset, ok = parse("offset"); !ok {
+		return nil, nil, false
+	}
+	return limit, offset, true
+}
@@ -0,0 +1,274 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/gateway"
+	"github.com/NGerasimovvv/GraphQL/internal/idempotency"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func newTestAPI(t *testing.T, store storage.Storage) http.Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := &API{
+		Posts:       gateway.NewPostGateway(store),
+		Comments:    gateway.NewCommentGateway(store, gateway.NewBannedWords([]string{"spam"}, gateway.Reject)),
+		Idempotency: gateway.NewIdempotencyGateway(store, time.Minute),
+	}
+	api.Register(r.Group("/api/v1", auth.Middleware(""), idempotency.Middleware()))
+	return r
+}
+
+func do(t *testing.T, h http.Handler, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &v), rec.Body.String())
+	return v
+}
+
+var moderator = map[string]string{auth.HeaderUserID: "mod", auth.HeaderRole: string(auth.RoleModerator)}
+
+// as signs a request in as a plain user, who may post under their own name.
+func as(id string) map[string]string {
+	return map[string]string{auth.HeaderUserID: id}
+}
+
+// uuidStorage rejects IDs that are not UUIDs the way Postgres does for its
+// UUID columns.
+type uuidStorage struct {
+	storage.Storage
+}
+
+func checkUUIDs(ids []string) error {
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			return &pgconn.PgError{Code: "22P02", Message: "invalid input syntax for type uuid: " + id}
+		}
+	}
+	return nil
+}
+
+func (s uuidStorage) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*models.Post, error) {
+	if err := checkUUIDs(ids); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetPostsByIDs(ctx, ids)
+}
+
+func (s uuidStorage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.CommentResponse, error) {
+	if err := checkUUIDs(ids); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetCommentsByIDs(ctx, ids)
+}
+
+func TestPosts_OldestFirst(t *testing.T) {
+	h := newTestAPI(t, storage.NewMemoryStorage())
+	var created []Post
+	for _, text := range []string{"first", "second", "third"} {
+		rec := do(t, h, http.MethodPost, "/api/v1/posts", `{"text":"`+text+`","author":"ann","commentable":true}`, as("ann"))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		created = append(created, decode[Post](t, rec))
+	}
+	assert.Equal(t, created, decode[[]Post](t, do(t, h, http.MethodGet, "/api/v1/posts", "", nil)))
+	assert.Equal(t, created[1:2], decode[[]Post](t, do(t, h, http.MethodGet, "/api/v1/posts?limit=1&offset=1", "", nil)))
+}
+
+func TestMalformedID(t *testing.T) {
+	h := newTestAPI(t, uuidStorage{storage.NewMemoryStorage()})
+
+	for _, target := range []string{"/api/v1/posts/abc", "/api/v1/posts/abc/comments", "/api/v1/comments/abc", "/api/v1/comments/abc/replies"} {
+		rec := do(t, h, http.MethodGet, target, "", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code, target)
+		assert.Equal(t, "NOT_FOUND", decode[Error](t, rec).Code, target)
+	}
+	rec := do(t, h, http.MethodPost, "/api/v1/posts/abc/comments", `{"text":"hi","author":"bob"}`, as("bob"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestPosts(t *testing.T) {
+	h := newTestAPI(t, storage.NewMemoryStorage())
+
+	rec := do(t, h, http.MethodPost, "/api/v1/posts", `{"text":"hello","author":"ann","commentable":true}`, as("ann"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	post := decode[Post](t, rec)
+	assert.Equal(t, "/api/v1/posts/"+post.ID, rec.Header().Get("Location"))
+	assert.Equal(t, Post{ID: post.ID, Text: "hello", Author: "ann", Commentable: true, Moderation: models.ModerationNone, Status: models.StatusVisible, Version: 1}, post)
+
+	rec = do(t, h, http.MethodGet, "/api/v1/posts/"+post.ID, "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, post, decode[Post](t, rec))
+
+	rec = do(t, h, http.MethodGet, "/api/v1/posts?limit=10", "", nil)
+	assert.Equal(t, []Post{post}, decode[[]Post](t, rec))
+
+	rec = do(t, h, http.MethodGet, "/api/v1/posts/missing", "", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, Error{Error: "post not found", Code: "NOT_FOUND"}, decode[Error](t, rec))
+
+	for _, body := range []string{`{"text":"hi","author":"ann"}`, `{"text":"hi","author":"ann","commentable":true,"moderation":"LATER"}`, `{`} {
+		rec = do(t, h, http.MethodPost, "/api/v1/posts", body, as("ann"))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Equal(t, "BAD_REQUEST", decode[Error](t, rec).Code)
+	}
+	rec = do(t, h, http.MethodGet, "/api/v1/posts?offset=-1", "", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Posts are written by the signed-in user and only under their own name.
+	rec = do(t, h, http.MethodPost, "/api/v1/posts", `{"text":"hi","author":"ann","commentable":true}`, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = do(t, h, http.MethodPost, "/api/v1/posts", `{"text":"hi","author":"ann","commentable":true}`, as("bob"))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "FORBIDDEN", decode[Error](t, rec).Code)
+}
+
+func TestComments(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	h := newTestAPI(t, store)
+	post := decode[Post](t, do(t, h, http.MethodPost, "/api/v1/posts", `{"text":"post","author":"ann","commentable":true}`, as("ann")))
+
+	rec := do(t, h, http.MethodPost, "/api/v1/posts/"+post.ID+"/comments", `{"text":"first","author":"bob"}`, as("bob"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	comment := decode[Comment](t, rec)
+	assert.Equal(t, "/api/v1/comments/"+comment.ID, rec.Header().Get("Location"))
+	assert.Equal(t, post.ID, comment.PostID)
+	assert.Nil(t, comment.ParentCommentID)
+
+	rec = do(t, h, http.MethodPost, "/api/v1/comments/"+comment.ID+"/replies", `{"text":"second","author":"ann"}`, as("ann"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	reply := decode[Comment](t, rec)
+	require.NotNil(t, reply.ParentCommentID)
+	assert.Equal(t, comment.ID, *reply.ParentCommentID)
+	assert.Equal(t, post.ID, reply.PostID)
+
+	rec = do(t, h, http.MethodGet, "/api/v1/posts/"+post.ID+"/comments", "", nil)
+	assert.Equal(t, []Comment{comment, reply}, decode[[]Comment](t, rec))
+	rec = do(t, h, http.MethodGet, "/api/v1/comments/"+comment.ID+"/replies", "", nil)
+	assert.Equal(t, []Comment{reply}, decode[[]Comment](t, rec))
+	rec = do(t, h, http.MethodGet, "/api/v1/comments/"+reply.ID, "", nil)
+	assert.Equal(t, reply, decode[Comment](t, rec))
+
+	// Comment ids are not posts and post ids are not comments.
+	assert.Equal(t, http.StatusNotFound, do(t, h, http.MethodPost, "/api/v1/posts/"+comment.ID+"/comments", `{"text":"x","author":"bob"}`, as("bob")).Code)
+	assert.Equal(t, http.StatusNotFound, do(t, h, http.MethodGet, "/api/v1/comments/"+post.ID+"/replies", "", nil).Code)
+
+	rec = do(t, h, http.MethodPost, "/api/v1/posts/"+post.ID+"/comments", `{"text":"buy spam","author":"bob"}`, as("bob"))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "CONTENT_REJECTED", decode[Error](t, rec).Code)
+
+	_, err := store.BanUser(context.Background(), &models.Sanction{UserID: "eve", Kind: models.SanctionKindBan, CreatedBy: "mod", CreatedAt: time.Now()})
+	require.NoError(t, err)
+	rec = do(t, h, http.MethodPost, "/api/v1/posts/"+post.ID+"/comments", `{"text":"hi","author":"eve"}`, as("eve"))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "BANNED", decode[Error](t, rec).Code)
+
+	closed := decode[Post](t, do(t, h, http.MethodPost, "/api/v1/posts", `{"text":"closed","author":"ann","commentable":false}`, as("ann")))
+	rec = do(t, h, http.MethodPost, "/api/v1/posts/"+closed.ID+"/comments", `{"text":"hi","author":"bob"}`, as("bob"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "NOT_COMMENTABLE", decode[Error](t, rec).Code)
+}
+
+func TestVisibility(t *testing.T) {
+	h := newTestAPI(t, storage.NewMemoryStorage())
+	post := decode[Post](t, do(t, h, http.MethodPost, "/api/v1/posts", `{"text":"post","author":"ann","commentable":true,"moderation":"PRE"}`, as("ann")))
+	comment := decode[Comment](t, do(t, h, http.MethodPost, "/api/v1/posts/"+post.ID+"/comments", `{"text":"queued","author":"bob"}`, as("bob")))
+	require.Equal(t, models.StatusPending, comment.Status)
+
+	assert.Equal(t, []Comment{}, decode[[]Comment](t, do(t, h, http.MethodGet, "/api/v1/posts/"+post.ID+"/comments", "", nil)))
+	assert.Equal(t, http.StatusNotFound, do(t, h, http.MethodGet, "/api/v1/comments/"+comment.ID, "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(t, h, http.MethodPost, "/api/v1/comments/"+comment.ID+"/replies", `{"text":"x","author":"ann"}`, as("ann")).Code)
+
+	assert.Equal(t, []Comment{comment}, decode[[]Comment](t, do(t, h, http.MethodGet, "/api/v1/posts/"+post.ID+"/comments", "", moderator)))
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/api/v1/comments/"+comment.ID, "", moderator).Code)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	h := newTestAPI(t, storage.NewMemoryStorage())
+	key := as("ann")
+	key[idempotency.Header] = "k1"
+	body := `{"text":"once","author":"ann","commentable":false}`
+
+	first := decode[Post](t, do(t, h, http.MethodPost, "/api/v1/posts", body, key))
+	second := decode[Post](t, do(t, h, http.MethodPost, "/api/v1/posts", body, key))
+	assert.Equal(t, first.ID, second.ID)
+	assert.Len(t, decode[[]Post](t, do(t, h, http.MethodGet, "/api/v1/posts", "", nil)), 1)
+
+	rec := do(t, h, http.MethodPost, "/api/v1/posts", `{"text":"twice","author":"ann","commentable":false}`, key)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "IDEMPOTENCY_KEY_REUSED", decode[Error](t, rec).Code)
+}
+
+func TestAudit(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	h := newTestAPI(t, store)
+	post := decode[Post](t, do(t, h, http.MethodPost, "/api/v1/posts", `{"text":"post","author":"ann","commentable":true}`, as("ann")))
+	do(t, h, http.MethodPost, "/api/v1/posts/"+post.ID+"/comments", `{"text":"hi","author":"bob"}`, as("bob"))
+
+	// REST writes go through the gateways, which audit them like GraphQL ones.
+	page, err := store.GetAuditLog(context.Background(), nil, 10, "")
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 2)
+	assert.Equal(t, "createComment", page.Entries[0].Action)
+	assert.Equal(t, "bob", *page.Entries[0].Actor)
+	assert.Equal(t, "createPost", page.Entries[1].Action)
+	assert.Equal(t, post.ID, *page.Entries[1].TargetID)
+}
+
+func TestSpec(t *testing.T) {
+	h := newTestAPI(t, storage.NewMemoryStorage())
+	rec := do(t, h, http.MethodGet, "/api/v1/openapi.yaml", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var spec struct {
+		OpenAPI string                    `yaml:"openapi"`
+		Paths   map[string]map[string]any `yaml:"paths"`
+	}
+	require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &spec))
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+
+	// Every route is documented and every documented operation is routed.
+	routes := map[string]bool{}
+	for _, route := range h.(*gin.Engine).Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+	delete(routes, "GET /api/v1/openapi.yaml")
+	documented := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			path := "/api/v1" + strings.NewReplacer("{", ":", "}", "").Replace(path)
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	assert.Equal(t, routes, documented)
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"strconv"
+
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+)
+
+// Audit cursors are the sequence number of the last entry on a page, like
+// queue cursors, but the log is read newest first.
+
+// matchesAudit reports whether entry passes every condition set in filter.
+func matchesAudit(entry *models.AuditEntry, filter *models.AuditFilter) bool {
+	if filter == nil {
+		return true
+	}
+	equal := func(want, have *string) bool {
+		return want == nil || have != nil && *have == *want
+	}
+	switch {
+	case !equal(filter.Actor, entry.Actor),
+		filter.Action != nil && *filter.Action != entry.Action,
+		!equal(filter.TargetID, entry.TargetID),
+		filter.Since != nil && entry.At.Before(*filter.Since),
+		filter.Until != nil && !entry.At.Before(*filter.Until):
+		return false
+	}
+	return true
+}
+
+// auditPage turns up to first+1 entries, newest first, into a page of at
+// most first.
+func auditPage(entries []*models.AuditEntry, seqs []uint64, first int) *models.AuditLogPage {
+	page := &models.AuditLogPage{Entries: entries}
+	if len(entries) > first {
+		page.Entries = entries[:first]
+		page.HasNextPage = true
+	}
+	if n := len(page.Entries); n > 0 {
+		cursor := strconv.FormatUint(seqs[n-1], 10)
+		page.EndCursor = &cursor
+	}
+	if page.Entries == nil {
+		page.Entries = []*models.AuditEntry{}
+	}
+	return page
+}
@@ -0,0 +1,497 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/cache"
+	"github.com/NGerasimovvv/GraphQL/internal/config"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/google/uuid"
+)
+
+// CachedStorage is a read-through cache in front of another Storage.
+//
+// Single items are cached under their ID. Lists are cached under a key that
+// embeds a generation token of the item they belong to (a post, a parent
+// comment or the global list). A write replaces the generation token, which
+// makes every page of that list unreachable at once; the stale entries age
+// out of the cache on their own. Cache errors are logged and fall through to
+// the underlying storage.
+//
+// Every Storage method is spelled out rather than promoted from an embedded
+// Storage, so a method added to the interface does not compile here until
+// someone decides whether it has to invalidate.
+type CachedStorage struct {
+	next  Storage
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+var _ Storage = (*CachedStorage)(nil)
+
+func NewCachedStorage(s Storage, c cache.Cache, ttl time.Duration) *CachedStorage {
+	return &CachedStorage{next: s, cache: c, ttl: ttl}
+}
+
+// WithCache wraps s in a CachedStorage when the config enables caching.
+func WithCache(cfg *config.Config, s Storage) Storage {
+	switch cfg.Cache.Type {
+	case config.CacheMemory:
+		return NewCachedStorage(s, cache.NewLRU(cfg.Cache.Size, cfg.Cache.TTL), cfg.Cache.TTL)
+	default:
+		return s
+	}
+}
+
+const (
+	allPostsGen    = "posts"
+	allCommentsGen = "comments"
+)
+
+func postKey(id string) string    { return "post:" + id }
+func commentKey(id string) string { return "comment:" + id }
+func genKey(scope string) string  { return "gen:" + scope }
+
+func pageKey(limit, offset *int) string {
+	l, o := "-", "-"
+	if limit != nil {
+		l = strconv.Itoa(*limit)
+	}
+	if offset != nil {
+		o = strconv.Itoa(*offset)
+	}
+	return l + ":" + o
+}
+
+func (s *CachedStorage) GetAllPosts(ctx context.Context, limit, offset *int) ([]*models.Post, error) {
+	key := s.listKey(ctx, allPostsGen, "posts:"+pageKey(limit, offset))
+	var posts []*models.Post
+	if s.load(ctx, key, &posts) {
+		return posts, nil
+	}
+	posts, err := s.next.GetAllPosts(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	s.store(ctx, key, stripPosts(posts))
+	return posts, nil
+}
+
+func (s *CachedStorage) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
+	key := postKey(postID)
+	var post *models.Post
+	if s.load(ctx, key, &post) && post != nil {
+		return post, nil
+	}
+	post, err := s.next.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	s.store(ctx, key, stripPosts([]*models.Post{post})[0])
+	return post, nil
+}
+
+func (s *CachedStorage) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*models.Post, error) {
+	posts := make(map[string]*models.Post, len(ids))
+	var misses []string
+	for _, id := range ids {
+		var post *models.Post
+		if s.load(ctx, postKey(id), &post) && post != nil {
+			posts[id] = post
+			continue
+		}
+		misses = append(misses, id)
+	}
+	if len(misses) == 0 {
+		return posts, nil
+	}
+
+	loaded, err := s.next.GetPostsByIDs(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+	for id, post := range loaded {
+		posts[id] = post
+		s.store(ctx, postKey(id), stripPosts([]*models.Post{post})[0])
+	}
+	return posts, nil
+}
+
+func (s *CachedStorage) CreatePost(ctx context.Context, id, textPost string, commentable bool, authorPost string, moderation models.Moderation) (*models.Post, error) {
+	post, err := s.next.CreatePost(ctx, id, textPost, commentable, authorPost, moderation)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, []string{postKey(id)}, allPostsGen, postKey(id))
+	return post, nil
+}
+
+func (s *CachedStorage) UpdatePost(ctx context.Context, id, textPost string, expectedVersion int) (*models.Post, error) {
+	post, err := s.next.UpdatePost(ctx, id, textPost, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, []string{postKey(id)}, allPostsGen, postKey(id))
+	return post, nil
+}
+
+func (s *CachedStorage) SetPostStatus(ctx context.Context, id string, status models.Status) (*models.Post, error) {
+	post, err := s.next.SetPostStatus(ctx, id, status)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, []string{postKey(id)}, allPostsGen, postKey(id))
+	return post, nil
+}
+
+func (s *CachedStorage) GetAllComments(ctx context.Context, limit, offset *int) ([]*models.CommentResponse, error) {
+	key := s.listKey(ctx, allCommentsGen, "comments:"+pageKey(limit, offset))
+	return s.loadComments(ctx, key, func() ([]*models.CommentResponse, error) {
+		return s.next.GetAllComments(ctx, limit, offset)
+	})
+}
+
+func (s *CachedStorage) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*models.CommentResponse, error) {
+	key := s.commentsKey(ctx, postID, limit, offset)
+	return s.loadComments(ctx, key, func() ([]*models.CommentResponse, error) {
+		return s.next.GetCommentsByPostID(ctx, postID, limit, offset)
+	})
+}
+
+func (s *CachedStorage) GetCommentsByPostIDs(ctx context.Context, postIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error) {
+	comments := make(map[string][]*models.CommentResponse, len(postIDs))
+	keys := make(map[string]string, len(postIDs))
+	var misses []string
+	for _, postID := range postIDs {
+		key := s.commentsKey(ctx, postID, limit, offset)
+		var byPost []*models.CommentResponse
+		if s.load(ctx, key, &byPost) {
+			comments[postID] = byPost
+			continue
+		}
+		keys[postID] = key
+		misses = append(misses, postID)
+	}
+	if len(misses) == 0 {
+		return comments, nil
+	}
+
+	loaded, err := s.next.GetCommentsByPostIDs(ctx, misses, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for _, postID := range misses {
+		comments[postID] = loaded[postID]
+		s.store(ctx, keys[postID], stripComments(loaded[postID]))
+	}
+	return comments, nil
+}
+
+func (s *CachedStorage) GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*models.CommentResponse, error) {
+	key := s.repliesKey(ctx, parentID, limit, offset)
+	return s.loadComments(ctx, key, func() ([]*models.CommentResponse, error) {
+		return s.next.GetCommentsByParentID(ctx, parentID, limit, offset)
+	})
+}
+
+func (s *CachedStorage) GetCommentsByParentIDs(ctx context.Context, parentIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error) {
+	replies := make(map[string][]*models.CommentResponse, len(parentIDs))
+	keys := make(map[string]string, len(parentIDs))
+	var misses []string
+	for _, parentID := range parentIDs {
+		key := s.repliesKey(ctx, parentID, limit, offset)
+		var comments []*models.CommentResponse
+		if s.load(ctx, key, &comments) {
+			replies[parentID] = comments
+			continue
+		}
+		keys[parentID] = key
+		misses = append(misses, parentID)
+	}
+	if len(misses) == 0 {
+		return replies, nil
+	}
+
+	loaded, err := s.next.GetCommentsByParentIDs(ctx, misses, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for _, parentID := range misses {
+		replies[parentID] = loaded[parentID]
+		s.store(ctx, keys[parentID], stripComments(loaded[parentID]))
+	}
+	return replies, nil
+}
+
+func (s *CachedStorage) GetCommentTree(ctx context.Context, postID string, maxDepth, perLevelLimit int) ([]*models.CommentResponse, error) {
+	key := s.listKey(ctx, postKey(postID), fmt.Sprintf("%s:tree:%d:%d", postKey(postID), maxDepth, perLevelLimit))
+	var tree []*models.CommentResponse
+	if s.load(ctx, key, &tree) {
+		return tree, nil
+	}
+	tree, err := s.next.GetCommentTree(ctx, postID, maxDepth, perLevelLimit)
+	if err != nil {
+		return nil, err
+	}
+	s.store(ctx, key, tree)
+	return tree, nil
+}
+
+func (s *CachedStorage) GetCommentByID(ctx context.Context, id string) (*models.CommentResponse, error) {
+	key := commentKey(id)
+	var comment *models.CommentResponse
+	if s.load(ctx, key, &comment) && comment != nil {
+		return comment, nil
+	}
+	comment, err := s.next.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.store(ctx, key, stripComments([]*models.CommentResponse{comment})[0])
+	return comment, nil
+}
+
+func (s *CachedStorage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.CommentResponse, error) {
+	comments := make(map[string]*models.CommentResponse, len(ids))
+	var misses []string
+	for _, id := range ids {
+		var comment *models.CommentResponse
+		if s.load(ctx, commentKey(id), &comment) && comment != nil {
+			comments[id] = comment
+			continue
+		}
+		misses = append(misses, id)
+	}
+	if len(misses) == 0 {
+		return comments, nil
+	}
+
+	loaded, err := s.next.GetCommentsByIDs(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+	for id, comment := range loaded {
+		comments[id] = comment
+		s.store(ctx, commentKey(id), stripComments([]*models.CommentResponse{comment})[0])
+	}
+	return comments, nil
+}
+
+func (s *CachedStorage) CreateComment(ctx context.Context, textComment, itemId, user string) (*models.CommentResponse, error) {
+	comment, err := s.next.CreateComment(ctx, textComment, itemId, user)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateComment(ctx, comment)
+	return comment, nil
+}
+
+func (s *CachedStorage) UpdateComment(ctx context.Context, id, textComment string, expectedVersion int) (*models.CommentResponse, error) {
+	comment, err := s.next.UpdateComment(ctx, id, textComment, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateComment(ctx, comment)
+	return comment, nil
+}
+
+func (s *CachedStorage) SetCommentStatus(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	comment, err := s.next.SetCommentStatus(ctx, id, status)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateComment(ctx, comment)
+	return comment, nil
+}
+
+func (s *CachedStorage) ReviewComment(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	comment, err := s.next.ReviewComment(ctx, id, status)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateComment(ctx, comment)
+	return comment, nil
+}
+
+// The methods below neither read nor change anything the cache holds, so
+// they go straight to the underlying storage. Reports hide items through
+// SetPostStatus and SetCommentStatus, which invalidate.
+
+func (s *CachedStorage) Ping(ctx context.Context) error {
+	return s.next.Ping(ctx)
+}
+
+func (s *CachedStorage) GetRevisions(ctx context.Context, itemID string) ([]*models.Revision, error) {
+	return s.next.GetRevisions(ctx, itemID)
+}
+
+func (s *CachedStorage) GetModerationQueue(ctx context.Context, first int, after string) (*models.ModerationQueue, error) {
+	return s.next.GetModerationQueue(ctx, first, after)
+}
+
+func (s *CachedStorage) QueueComment(ctx context.Context, id string) error {
+	return s.next.QueueComment(ctx, id)
+}
+
+func (s *CachedStorage) CreateReport(ctx context.Context, itemID, reporter string, reason models.ReportReason, note *string) (*models.Report, int, error) {
+	return s.next.CreateReport(ctx, itemID, reporter, reason, note)
+}
+
+func (s *CachedStorage) GetReports(ctx context.Context, limit, offset *int) ([]*models.ReportGroup, error) {
+	return s.next.GetReports(ctx, limit, offset)
+}
+
+func (s *CachedStorage) CheckSanction(ctx context.Context, userID string, comment bool) error {
+	return s.next.CheckSanction(ctx, userID, comment)
+}
+
+func (s *CachedStorage) BanUser(ctx context.Context, sanction *models.Sanction) (*models.Sanction, error) {
+	return s.next.BanUser(ctx, sanction)
+}
+
+func (s *CachedStorage) UnbanUser(ctx context.Context, userID string) (bool, error) {
+	return s.next.UnbanUser(ctx, userID)
+}
+
+func (s *CachedStorage) SweepSanctions(ctx context.Context, now time.Time) (int, error) {
+	return s.next.SweepSanctions(ctx, now)
+}
+
+func (s *CachedStorage) BlockUser(ctx context.Context, postID, userID string) error {
+	return s.next.BlockUser(ctx, postID, userID)
+}
+
+func (s *CachedStorage) UnblockUser(ctx context.Context, postID, userID string) (bool, error) {
+	return s.next.UnblockUser(ctx, postID, userID)
+}
+
+func (s *CachedStorage) ClaimIdempotencyKey(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	return s.next.ClaimIdempotencyKey(ctx, record)
+}
+
+func (s *CachedStorage) CompleteIdempotencyKey(ctx context.Context, key string, result []byte, expiresAt time.Time) error {
+	return s.next.CompleteIdempotencyKey(ctx, key, result, expiresAt)
+}
+
+func (s *CachedStorage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	return s.next.ReleaseIdempotencyKey(ctx, key)
+}
+
+func (s *CachedStorage) SweepIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	return s.next.SweepIdempotencyKeys(ctx, now)
+}
+
+func (s *CachedStorage) AppendAudit(ctx context.Context, entry *models.AuditEntry) error {
+	return s.next.AppendAudit(ctx, entry)
+}
+
+func (s *CachedStorage) GetAuditLog(ctx context.Context, filter *models.AuditFilter, first int, after string) (*models.AuditLogPage, error) {
+	return s.next.GetAuditLog(ctx, filter, first, after)
+}
+
+func (s *CachedStorage) invalidateComment(ctx context.Context, comment *models.CommentResponse) {
+	scopes := []string{allCommentsGen, postKey(comment.PostID)}
+	if comment.ParentCommentID != nil {
+		scopes = append(scopes, commentKey(*comment.ParentCommentID))
+	}
+	s.invalidate(ctx, []string{commentKey(comment.ID)}, scopes...)
+}
+
+func (s *CachedStorage) commentsKey(ctx context.Context, postID string, limit, offset *int) string {
+	return s.listKey(ctx, postKey(postID), postKey(postID)+":comments:"+pageKey(limit, offset))
+}
+
+func (s *CachedStorage) repliesKey(ctx context.Context, parentID string, limit, offset *int) string {
+	return s.listKey(ctx, commentKey(parentID), commentKey(parentID)+":replies:"+pageKey(limit, offset))
+}
+
+// listKey prefixes key with the current generation token of scope.
+func (s *CachedStorage) listKey(ctx context.Context, scope, key string) string {
+	gen, ok, err := s.cache.Get(ctx, genKey(scope))
+	if err != nil {
+		log.Printf("cache: get %s: %v", genKey(scope), err)
+	}
+	if !ok {
+		gen = []byte("0")
+	}
+	return string(gen) + ":" + key
+}
+
+func (s *CachedStorage) invalidate(ctx context.Context, keys []string, scopes ...string) {
+	if err := s.cache.Delete(ctx, keys...); err != nil {
+		log.Printf("cache: delete %v: %v", keys, err)
+	}
+	for _, scope := range scopes {
+		// The generation must outlive every list entry built on it.
+		if err := s.cache.Set(ctx, genKey(scope), []byte(uuid.NewString()), 2*s.ttl); err != nil {
+			log.Printf("cache: set %s: %v", genKey(scope), err)
+		}
+	}
+}
+
+func (s *CachedStorage) loadComments(ctx context.Context, key string, fetch func() ([]*models.CommentResponse, error)) ([]*models.CommentResponse, error) {
+	var comments []*models.CommentResponse
+	if s.load(ctx, key, &comments) {
+		return comments, nil
+	}
+	comments, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	s.store(ctx, key, stripComments(comments))
+	return comments, nil
+}
+
+func (s *CachedStorage) load(ctx context.Context, key string, v any) bool {
+	data, ok, err := s.cache.Get(ctx, key)
+	if err != nil {
+		log.Printf("cache: get %s: %v", key, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Printf("cache: decode %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+func (s *CachedStorage) store(ctx context.Context, key string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("cache: encode %s: %v", key, err)
+		return
+	}
+	if err := s.cache.Set(ctx, key, data, s.ttl); err != nil {
+		log.Printf("cache: set %s: %v", key, err)
+	}
+}
+
+// stripPosts and stripComments drop the nested lists resolvers attach to
+// results, so a cached item never carries another request's children.
+func stripPosts(posts []*models.Post) []*models.Post {
+	stripped := make([]*models.Post, len(posts))
+	for i, p := range posts {
+		c := *p
+		c.Comments = nil
+		stripped[i] = &c
+	}
+	return stripped
+}
+
+func stripComments(comments []*models.CommentResponse) []*models.CommentResponse {
+	stripped := make([]*models.CommentResponse, len(comments))
+	for i, comment := range comments {
+		c := *comment
+		c.Replies = nil
+		stripped[i] = &c
+	}
+	return stripped
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteCache stands in for a networked cache such as Redis: it only keeps
+// bytes, ignores expiry and counts round trips.
+type remoteCache struct {
+	mu    sync.Mutex
+	data  map[string][]byte
+	gets  int
+	hits  int
+	fails bool
+}
+
+func newRemoteCache() *remoteCache {
+	return &remoteCache{data: make(map[string][]byte)}
+}
+
+func (c *remoteCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	if c.fails {
+		return nil, false, context.DeadlineExceeded
+	}
+	v, ok := c.data[key]
+	if ok {
+		c.hits++
+	}
+	return append([]byte(nil), v...), ok, nil
+}
+
+func (c *remoteCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fails {
+		return context.DeadlineExceeded
+	}
+	c.data[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (c *remoteCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.data, key)
+	}
+	return nil
+}
+
+func TestCachedStorage_ReadThroughAndInvalidate(t *testing.T) {
+	ctx := context.Background()
+	remote := newRemoteCache()
+	s := NewCachedStorage(NewMemoryStorage(), remote, time.Minute)
+
+	_, err := s.CreatePost(ctx, "p1", "post", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+	first, err := s.CreateComment(ctx, "first", "p1", "author")
+	require.NoError(t, err)
+
+	comments, err := s.GetCommentsByPostID(ctx, "p1", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+
+	hits := remote.hits
+	comments, err = s.GetCommentsByPostID(ctx, "p1", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Greater(t, remote.hits, hits, "second read is served from the cache")
+
+	_, err = s.CreateComment(ctx, "reply", first.ID, "author")
+	require.NoError(t, err)
+
+	comments, err = s.GetCommentsByPostID(ctx, "p1", nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, comments, 2, "creating a reply invalidates the post's comment pages")
+
+	replies, err := s.GetCommentsByParentIDs(ctx, []string{first.ID}, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, replies[first.ID], 1)
+}
+
+func TestCachedStorage_CachedItemsAreIndependentCopies(t *testing.T) {
+	ctx := context.Background()
+	s := NewCachedStorage(NewMemoryStorage(), newRemoteCache(), time.Minute)
+	_, err := s.CreatePost(ctx, "p1", "post", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+
+	post, err := s.GetPostByID(ctx, "p1")
+	require.NoError(t, err)
+	post.TextPost = "changed by a resolver"
+
+	cached, err := s.GetPostByID(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "post", cached.TextPost)
+}
+
+func TestCachedStorage_FallsThroughOnCacheErrors(t *testing.T) {
+	ctx := context.Background()
+	remote := newRemoteCache()
+	remote.fails = true
+	s := NewCachedStorage(NewMemoryStorage(), remote, time.Minute)
+
+	_, err := s.CreatePost(ctx, "p1", "post", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+	post, err := s.GetPostByID(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "p1", post.ID)
+}
+
+// Every write that changes a cached post or comment must evict it, or the
+// next read returns the old version.
+func TestCachedStorage_WritesInvalidate(t *testing.T) {
+	ctx := context.Background()
+	s := NewCachedStorage(NewMemoryStorage(), newRemoteCache(), time.Minute)
+	_, err := s.CreatePost(ctx, "p1", "post", true, "author", models.ModerationPre)
+	require.NoError(t, err)
+	comment, err := s.CreateComment(ctx, "comment", "p1", "author")
+	require.NoError(t, err)
+
+	writes := []struct {
+		name  string
+		write func() error
+		check func(post *models.Post, comment *models.CommentResponse)
+	}{
+		{"UpdatePost", func() error {
+			_, err := s.UpdatePost(ctx, "p1", "edited", 1)
+			return err
+		}, func(post *models.Post, _ *models.CommentResponse) { assert.Equal(t, "edited", post.TextPost) }},
+		{"SetPostStatus", func() error {
+			_, err := s.SetPostStatus(ctx, "p1", models.StatusHidden)
+			return err
+		}, func(post *models.Post, _ *models.CommentResponse) { assert.Equal(t, models.StatusHidden, post.Status) }},
+		{"ReviewComment", func() error {
+			_, err := s.ReviewComment(ctx, comment.ID, models.StatusVisible)
+			return err
+		}, func(_ *models.Post, c *models.CommentResponse) { assert.Equal(t, models.StatusVisible, c.Status) }},
+		{"UpdateComment", func() error {
+			_, err := s.UpdateComment(ctx, comment.ID, "edited", 2)
+			return err
+		}, func(_ *models.Post, c *models.CommentResponse) { assert.Equal(t, "edited", c.TextComment) }},
+		{"SetCommentStatus", func() error {
+			_, err := s.SetCommentStatus(ctx, comment.ID, models.StatusDeleted)
+			return err
+		}, func(_ *models.Post, c *models.CommentResponse) { assert.Equal(t, models.StatusDeleted, c.Status) }},
+	}
+	for _, w := range writes {
+		// Warm the cache, write, then read again.
+		_, err := s.GetPostByID(ctx, "p1")
+		require.NoError(t, err)
+		_, err = s.GetCommentByID(ctx, comment.ID)
+		require.NoError(t, err)
+
+		require.NoError(t, w.write(), w.name)
+		post, err := s.GetPostByID(ctx, "p1")
+		require.NoError(t, err)
+		c, err := s.GetCommentByID(ctx, comment.ID)
+		require.NoError(t, err)
+		w.check(post, c)
+	}
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was used with different arguments")
+)
+
+// IdempotencyRecord ties an idempotency key to the request that claimed it.
+// Result stays nil until that request succeeds.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	Result      []byte
+	ExpiresAt   time.Time
+}
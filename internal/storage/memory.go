@@ -1,60 +1,177 @@
 package storage
 
 import (
+	"container/heap"
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/NGerasimovvv/GraphQL/internal/models"
 	"github.com/google/uuid"
 )
 
+const memoryShards = 64
+
+// InMemoryStorage stripes its data over shards by post ID. A comment lives
+// in the shard of its post, so creating a comment locks a single shard and
+// the commentable check and the insert happen under the same lock. Reads
+// that span posts snapshot each shard's list under its read lock and merge
+// the snapshots by a global insertion sequence. List entries never change
+// once appended, and stored items are only mutated under their shard's write
+// lock, so reads always hand out copies taken under the read lock.
 type InMemoryStorage struct {
-	postCounter    int
-	commentCounter int
-	posts          map[string]*models.Post
-	comments       map[string]*models.CommentResponse
-	mu             sync.RWMutex
+	shards [memoryShards]*memoryShard
+	// commentShards maps a comment ID to the shard of its post.
+	commentShards sync.Map
+	seq           atomic.Uint64
+
+	sanctionsMu sync.RWMutex
+	sanctions   map[string]*models.Sanction
+
+	idempotencyMu   sync.Mutex
+	idempotencyKeys map[string]*IdempotencyRecord
+
+	// audit is append-only; an entry's sequence number is its index plus one.
+	auditMu sync.RWMutex
+	audit   []*models.AuditEntry
+}
+
+// memoryShard keeps posts and comments in maps for lookups by ID and in
+// insertion-ordered slices for listing. Comments are also indexed by post,
+// by parent comment and, for top-level comments, by post only, so listing
+// never scans unrelated items and pages are stable between calls.
+type memoryShard struct {
+	mu       sync.RWMutex
+	posts    map[string]*models.Post
+	comments map[string]*models.CommentResponse
+
+	postList         []seqPost
+	commentList      []seqComment
+	commentsByPost   map[string][]*models.CommentResponse
+	commentsByParent map[string][]*models.CommentResponse
+	rootsByPost      map[string][]*models.CommentResponse
+	// queue maps comments awaiting moderation to their sequence numbers.
+	queue map[string]uint64
+	// reports holds the reports filed against the shard's posts and comments.
+	reports map[string][]*models.Report
+	// blocks holds the users each post's author has blocked from commenting.
+	blocks map[string]map[string]bool
+	// revisions holds every version of the shard's post and comment texts.
+	revisions map[string][]*models.Revision
+}
+
+type seqPost struct {
+	seq  uint64
+	post *models.Post
+}
+
+type seqComment struct {
+	seq     uint64
+	comment *models.CommentResponse
 }
 
 func NewMemoryStorage() *InMemoryStorage {
-	return &InMemoryStorage{
-		postCounter:    0,
-		commentCounter: 0,
-		posts:          make(map[string]*models.Post),
-		comments:       make(map[string]*models.CommentResponse),
+	s := &InMemoryStorage{sanctions: make(map[string]*models.Sanction), idempotencyKeys: make(map[string]*IdempotencyRecord)}
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{
+			posts:            make(map[string]*models.Post),
+			comments:         make(map[string]*models.CommentResponse),
+			commentsByPost:   make(map[string][]*models.CommentResponse),
+			commentsByParent: make(map[string][]*models.CommentResponse),
+			rootsByPost:      make(map[string][]*models.CommentResponse),
+			queue:            make(map[string]uint64),
+			reports:          make(map[string][]*models.Report),
+			blocks:           make(map[string]map[string]bool),
+			revisions:        make(map[string][]*models.Revision),
+		}
 	}
+	return s
 }
 
 func InitMemoryStorage() *InMemoryStorage {
-	storage := NewMemoryStorage()
-	storage.mu.Lock()
-	defer storage.mu.Unlock()
-	return storage
+	return NewMemoryStorage()
+}
+
+func (s *InMemoryStorage) postShard(postID string) *memoryShard {
+	h := fnv.New32a()
+	h.Write([]byte(postID))
+	return s.shards[h.Sum32()%memoryShards]
+}
+
+func (s *InMemoryStorage) commentShard(commentID string) (*memoryShard, bool) {
+	sh, ok := s.commentShards.Load(commentID)
+	if !ok {
+		return nil, false
+	}
+	return sh.(*memoryShard), true
+}
+
+func (s *InMemoryStorage) Ping(ctx context.Context) error {
+	return nil
 }
 
 func (s *InMemoryStorage) GetAllPosts(ctx context.Context, limit, offset *int) ([]*models.Post, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	var posts []*models.Post
-	for _, post := range s.posts {
-		posts = append(posts, post)
+	lists := make([][]seqPost, memoryShards)
+	for i, sh := range s.shards {
+		sh.mu.RLock()
+		lists[i] = sh.postList
+		sh.mu.RUnlock()
 	}
+	return mergeBySeq(lists, func(p seqPost) uint64 { return p.seq }, func(i int, p seqPost) *models.Post {
+		sh := s.shards[i]
+		sh.mu.RLock()
+		defer sh.mu.RUnlock()
+		return clonePost(p.post)
+	}, limit, offset), nil
+}
 
-	if limit != nil && offset != nil {
-		start := *offset
-		end := *offset + *limit
-		if start > len(posts) {
-			return []*models.Post{}, nil
-		}
-		if end > len(posts) {
-			end = len(posts)
-		}
-		posts = posts[start:end]
+// clonePost and cloneComment copy a stored item without the nested lists
+// resolvers attach to it.
+func clonePost(p *models.Post) *models.Post {
+	c := *p
+	c.Comments = nil
+	return &c
+}
+
+func cloneComment(comment *models.CommentResponse) *models.CommentResponse {
+	c := *comment
+	c.Replies = nil
+	return &c
+}
+
+func cloneComments(comments []*models.CommentResponse) []*models.CommentResponse {
+	for i, comment := range comments {
+		comments[i] = cloneComment(comment)
 	}
+	return comments
+}
 
-	return posts, nil
+// page returns a copy of the requested window so callers never share the
+// backing array of an index. A nil limit or offset means no limit or offset.
+func page[T any](items []T, limit, offset *int) []T {
+	start, end := window(len(items), limit, offset)
+	result := make([]T, end-start)
+	copy(result, items[start:end])
+	return result
+}
+
+func window(n int, limit, offset *int) (start, end int) {
+	if offset != nil && *offset > 0 {
+		start = *offset
+	}
+	if start > n {
+		start = n
+	}
+	end = n
+	if limit != nil && *limit >= 0 && start+*limit < end {
+		end = start + *limit
+	}
+	return start, end
 }
 
 func pagination(page, pageSize *int) (offset, limit int) {
@@ -75,139 +192,629 @@ func pagination(page, pageSize *int) (offset, limit int) {
 }
 
 func (s *InMemoryStorage) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	post, exists := s.posts[postID]
+	sh := s.postShard(postID)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	post, exists := sh.posts[postID]
 	if !exists {
 		return nil, fmt.Errorf("post not found")
 	}
-	return post, nil
+	return clonePost(post), nil
 }
 
-func (s *InMemoryStorage) CreatePost(ctx context.Context, id, text string, commentable bool, authorPost string) (*models.Post, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	post := &models.Post{ID: id, TextPost: text, Commentable: commentable, AuthorPost: authorPost}
-	s.posts[id] = post
-	return post, nil
+func (s *InMemoryStorage) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*models.Post, error) {
+	posts := make(map[string]*models.Post, len(ids))
+	for _, id := range ids {
+		if post, err := s.GetPostByID(ctx, id); err == nil {
+			posts[id] = post
+		}
+	}
+	return posts, nil
 }
 
-func (s *InMemoryStorage) GetAllComments(ctx context.Context, limit, offset *int) ([]*models.CommentResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	comments := make([]*models.CommentResponse, 0, len(s.comments))
-	for _, comment := range s.comments {
-		comments = append(comments, comment)
-	}
-	start := 0
-	if offset != nil {
-		start = *offset
+func (s *InMemoryStorage) CreatePost(ctx context.Context, id, text string, commentable bool, authorPost string, moderation models.Moderation) (*models.Post, error) {
+	if err := s.CheckSanction(ctx, authorPost, false); err != nil {
+		return nil, err
 	}
-	end := len(comments)
-	if limit != nil && start+*limit < end {
-		end = start + *limit
+	sh := s.postShard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	post := &models.Post{ID: id, TextPost: text, Commentable: commentable, AuthorPost: authorPost, Moderation: moderation, Status: models.StatusVisible, Version: 1}
+	// A post created again under its ID replaces the old one in place, as
+	// it always has in this storage; only its text history starts over.
+	if old, exists := sh.posts[id]; exists {
+		*old = *post
+		post = old
+		delete(sh.revisions, id)
+	} else {
+		sh.posts[id] = post
+		sh.postList = append(sh.postList, seqPost{seq: s.seq.Add(1), post: post})
 	}
-
-	return comments[start:end], nil
+	sh.addRevision(id, text)
+	return clonePost(post), nil
 }
 
-func (s *InMemoryStorage) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*models.CommentResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	var comments []*models.CommentResponse
-	for _, comment := range s.comments {
-		if comment.PostID == postID {
-			comments = append(comments, comment)
+func (s *InMemoryStorage) UpdatePost(ctx context.Context, id, textPost string, expectedVersion int) (*models.Post, error) {
+	return s.updatePost(id, func(sh *memoryShard, post *models.Post) error {
+		if post.Version != expectedVersion {
+			return &ConflictError{Current: post.Version}
+		}
+		if post.TextPost != textPost {
+			post.TextPost, post.Edited = textPost, true
+			sh.addRevision(id, textPost)
 		}
+		return nil
+	})
+}
+
+func (s *InMemoryStorage) SetPostStatus(ctx context.Context, id string, status models.Status) (*models.Post, error) {
+	return s.updatePost(id, func(sh *memoryShard, post *models.Post) error {
+		post.Status = status
+		return nil
+	})
+}
+
+// updatePost applies update under the shard lock and, if it succeeds, bumps
+// the post's version.
+func (s *InMemoryStorage) updatePost(id string, update func(*memoryShard, *models.Post) error) (*models.Post, error) {
+	sh := s.postShard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	post, exists := sh.posts[id]
+	if !exists {
+		return nil, fmt.Errorf("post not found")
 	}
+	if err := update(sh, post); err != nil {
+		return nil, err
+	}
+	post.Version++
+	return clonePost(post), nil
+}
 
-	start := 0
-	if offset != nil {
-		start = *offset
+func (s *InMemoryStorage) GetAllComments(ctx context.Context, limit, offset *int) ([]*models.CommentResponse, error) {
+	lists := make([][]seqComment, memoryShards)
+	for i, sh := range s.shards {
+		sh.mu.RLock()
+		lists[i] = sh.commentList
+		sh.mu.RUnlock()
 	}
-	end := len(comments)
-	if limit != nil {
-		end = start + *limit
-		if end > len(comments) {
-			end = len(comments)
+	return mergeBySeq(lists, func(c seqComment) uint64 { return c.seq }, func(i int, c seqComment) *models.CommentResponse {
+		sh := s.shards[i]
+		sh.mu.RLock()
+		defer sh.mu.RUnlock()
+		return cloneComment(c.comment)
+	}, limit, offset), nil
+}
+
+func (s *InMemoryStorage) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*models.CommentResponse, error) {
+	sh := s.postShard(postID)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return cloneComments(page(sh.commentsByPost[postID], limit, offset)), nil
+}
+
+func (s *InMemoryStorage) GetCommentsByPostIDs(ctx context.Context, postIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error) {
+	comments := make(map[string][]*models.CommentResponse, len(postIDs))
+	for _, postID := range postIDs {
+		byPost, err := s.GetCommentsByPostID(ctx, postID, limit, offset)
+		if err != nil {
+			return nil, err
 		}
+		comments[postID] = byPost
 	}
-	if start > len(comments) {
+	return comments, nil
+}
+
+func (s *InMemoryStorage) GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*models.CommentResponse, error) {
+	sh, ok := s.commentShard(parentID)
+	if !ok {
 		return []*models.CommentResponse{}, nil
 	}
-
-	return comments[start:end], nil
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return cloneComments(page(sh.commentsByParent[parentID], limit, offset)), nil
 }
 
-func (s *InMemoryStorage) GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*models.CommentResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	var comments []*models.CommentResponse
-	for _, comment := range s.comments {
-		if comment.ParentCommentID != nil && *comment.ParentCommentID == parentID {
-			comments = append(comments, comment)
+func (s *InMemoryStorage) GetCommentsByParentIDs(ctx context.Context, parentIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error) {
+	replies := make(map[string][]*models.CommentResponse, len(parentIDs))
+	for _, parentID := range parentIDs {
+		comments, err := s.GetCommentsByParentID(ctx, parentID, limit, offset)
+		if err != nil {
+			return nil, err
 		}
+		replies[parentID] = comments
 	}
+	return replies, nil
+}
 
-	start := 0
-	if offset != nil {
-		start = *offset
+func (s *InMemoryStorage) GetCommentTree(ctx context.Context, postID string, maxDepth, perLevelLimit int) ([]*models.CommentResponse, error) {
+	sh := s.postShard(postID)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	var limit *int
+	if perLevelLimit > 0 {
+		limit = &perLevelLimit
 	}
-	end := len(comments)
-	if limit != nil {
-		end = start + *limit
-		if end > len(comments) {
-			end = len(comments)
+
+	var ordered []*models.CommentResponse
+	var walk func(siblings []*models.CommentResponse, depth int)
+	walk = func(siblings []*models.CommentResponse, depth int) {
+		for _, comment := range page(siblings, limit, nil) {
+			ordered = append(ordered, cloneComment(comment))
+			if maxDepth <= 0 || depth < maxDepth {
+				walk(sh.commentsByParent[comment.ID], depth+1)
+			}
 		}
 	}
-	if start > len(comments) {
-		return []*models.CommentResponse{}, nil
-	}
+	walk(sh.rootsByPost[postID], 1)
 
-	return comments[start:end], nil
+	return buildTree(ordered), nil
 }
 
 func (s *InMemoryStorage) GetCommentByID(ctx context.Context, id string) (*models.CommentResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	comment, exists := s.comments[id]
+	sh, ok := s.commentShard(id)
+	if !ok {
+		return nil, fmt.Errorf("comment not found")
+	}
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	comment, exists := sh.comments[id]
+	if !exists {
+		return nil, fmt.Errorf("comment not found")
+	}
+	return cloneComment(comment), nil
+}
+
+func (s *InMemoryStorage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.CommentResponse, error) {
+	comments := make(map[string]*models.CommentResponse, len(ids))
+	for _, id := range ids {
+		if comment, err := s.GetCommentByID(ctx, id); err == nil {
+			comments[id] = comment
+		}
+	}
+	return comments, nil
+}
+
+func (s *InMemoryStorage) UpdateComment(ctx context.Context, id, textComment string, expectedVersion int) (*models.CommentResponse, error) {
+	return s.updateComment(id, func(sh *memoryShard, comment *models.CommentResponse) error {
+		if comment.Version != expectedVersion {
+			return &ConflictError{Current: comment.Version}
+		}
+		if comment.TextComment != textComment {
+			comment.TextComment, comment.Edited = textComment, true
+			sh.addRevision(id, textComment)
+		}
+		return nil
+	})
+}
+
+func (s *InMemoryStorage) SetCommentStatus(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	return s.updateComment(id, func(sh *memoryShard, comment *models.CommentResponse) error {
+		comment.Status = status
+		return nil
+	})
+}
+
+// updateComment applies update under the shard lock and, if it succeeds,
+// bumps the comment's version.
+func (s *InMemoryStorage) updateComment(id string, update func(*memoryShard, *models.CommentResponse) error) (*models.CommentResponse, error) {
+	sh, ok := s.commentShard(id)
+	if !ok {
+		return nil, fmt.Errorf("comment not found")
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	comment, exists := sh.comments[id]
 	if !exists {
 		return nil, fmt.Errorf("comment not found")
 	}
-	return comment, nil
+	if err := update(sh, comment); err != nil {
+		return nil, err
+	}
+	comment.Version++
+	return cloneComment(comment), nil
 }
 
 func (s *InMemoryStorage) CreateComment(ctx context.Context, commentText, itemId, user string) (*models.CommentResponse, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	var isReply bool
+	if err := s.CheckSanction(ctx, user, true); err != nil {
+		return nil, err
+	}
+	sh, isReply := s.commentShard(itemId)
+	if !isReply {
+		sh = s.postShard(itemId)
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
 	var parentCommentID *string
 	var postID string
-	var commentAble bool
+	var status models.Status
 
-	if post, exists := s.posts[itemId]; exists {
+	if post, exists := sh.posts[itemId]; exists && !isReply {
 		postID = itemId
-		isReply = false
-		commentAble = post.Commentable
-		if !commentAble {
-			return nil, errors.New("author turned off comments under this post")
+		if !post.Commentable {
+			return nil, fmt.Errorf("%w: author turned off comments under this post", ErrNotCommentable)
 		}
-	} else if comment, exists := s.comments[itemId]; exists {
+		status = post.Status
+	} else if comment, exists := sh.comments[itemId]; exists && isReply {
 		postID = comment.PostID
 		parentCommentID = &itemId
-		isReply = true
+		status = comment.Status
 	} else {
 		return nil, errors.New("item not found")
 	}
+	if status != models.StatusVisible {
+		return nil, ErrNotCommentable
+	}
+	if sh.blocks[postID][user] {
+		return nil, ErrBlocked
+	}
 
+	status, queued := moderatedStatus(sh.posts[postID].Moderation)
 	var newComment *models.CommentResponse
 	id := uuid.New().String()
 	if isReply {
-		newComment = &models.CommentResponse{ID: id, TextComment: commentText, AuthorComment: user, PostID: postID, ParentCommentID: parentCommentID}
+		newComment = &models.CommentResponse{ID: id, TextComment: commentText, AuthorComment: user, PostID: postID, ParentCommentID: parentCommentID, Status: status, Version: 1}
 	} else {
-		newComment = &models.CommentResponse{ID: id, TextComment: commentText, AuthorComment: user, PostID: postID}
+		newComment = &models.CommentResponse{ID: id, TextComment: commentText, AuthorComment: user, PostID: postID, Status: status, Version: 1}
 	}
-	s.comments[id] = newComment
+	seq := s.seq.Add(1)
+	sh.indexComment(newComment, seq)
+	sh.addRevision(id, commentText)
+	if queued {
+		sh.queue[id] = seq
+	}
+	s.commentShards.Store(id, sh)
+
+	return cloneComment(newComment), nil
+}
+
+func (s *InMemoryStorage) GetModerationQueue(ctx context.Context, first int, after string) (*models.ModerationQueue, error) {
+	afterSeq, err := parseQueueCursor(after)
+	if err != nil {
+		return nil, err
+	}
+	var queued []seqComment
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for id, seq := range sh.queue {
+			if seq > afterSeq {
+				queued = append(queued, seqComment{seq: seq, comment: cloneComment(sh.comments[id])})
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	sort.Slice(queued, func(i, j int) bool { return queued[i].seq < queued[j].seq })
+	if len(queued) > first+1 {
+		queued = queued[:first+1]
+	}
+
+	comments := make([]*models.CommentResponse, len(queued))
+	seqs := make([]uint64, len(queued))
+	for i, q := range queued {
+		comments[i], seqs[i] = q.comment, q.seq
+	}
+	return queuePage(comments, seqs, first), nil
+}
+
+func (s *InMemoryStorage) QueueComment(ctx context.Context, id string) error {
+	sh, ok := s.commentShard(id)
+	if !ok {
+		return fmt.Errorf("comment not found")
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, queued := sh.queue[id]; !queued {
+		sh.queue[id] = s.seq.Add(1)
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) ReviewComment(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	sh, ok := s.commentShard(id)
+	if !ok {
+		return nil, fmt.Errorf("comment not found")
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, queued := sh.queue[id]; !queued {
+		return nil, ErrNotQueued
+	}
+	delete(sh.queue, id)
+	comment := sh.comments[id]
+	comment.Status = status
+	comment.Version++
+	return cloneComment(comment), nil
+}
+
+func (s *InMemoryStorage) CreateReport(ctx context.Context, itemID, reporter string, reason models.ReportReason, note *string) (*models.Report, int, error) {
+	itemType := models.ItemTypeComment
+	sh, ok := s.commentShard(itemID)
+	if !ok {
+		itemType = models.ItemTypePost
+		sh = s.postShard(itemID)
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, exists := sh.posts[itemID]; itemType == models.ItemTypePost && !exists {
+		return nil, 0, errors.New("item not found")
+	}
+	for _, r := range sh.reports[itemID] {
+		if r.Reporter == reporter {
+			return nil, 0, ErrAlreadyReported
+		}
+	}
+
+	report := &models.Report{ID: uuid.New().String(), ItemID: itemID, ItemType: itemType, Reporter: reporter, Reason: reason, Note: note, CreatedAt: time.Now()}
+	sh.reports[itemID] = append(sh.reports[itemID], report)
+	c := *report
+	return &c, len(sh.reports[itemID]), nil
+}
+
+func (s *InMemoryStorage) GetReports(ctx context.Context, limit, offset *int) ([]*models.ReportGroup, error) {
+	var groups []*models.ReportGroup
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for itemID, reports := range sh.reports {
+			group := &models.ReportGroup{ItemID: itemID, ItemType: reports[0].ItemType, Count: len(reports)}
+			for _, r := range reports {
+				c := *r
+				group.Reports = append(group.Reports, &c)
+			}
+			groups = append(groups, group)
+		}
+		sh.mu.RUnlock()
+	}
+	sortReportGroups(groups)
+	return page(groups, limit, offset), nil
+}
+
+func (s *InMemoryStorage) CheckSanction(ctx context.Context, userID string, comment bool) error {
+	s.sanctionsMu.RLock()
+	defer s.sanctionsMu.RUnlock()
+	return sanctionError(s.sanctions[userID], comment, time.Now())
+}
+
+func (s *InMemoryStorage) BanUser(ctx context.Context, sanction *models.Sanction) (*models.Sanction, error) {
+	c := *sanction
+	s.sanctionsMu.Lock()
+	s.sanctions[sanction.UserID] = &c
+	s.sanctionsMu.Unlock()
+	return sanction, nil
+}
+
+func (s *InMemoryStorage) UnbanUser(ctx context.Context, userID string) (bool, error) {
+	s.sanctionsMu.Lock()
+	defer s.sanctionsMu.Unlock()
+	_, exists := s.sanctions[userID]
+	delete(s.sanctions, userID)
+	return exists, nil
+}
+
+func (s *InMemoryStorage) SweepSanctions(ctx context.Context, now time.Time) (int, error) {
+	s.sanctionsMu.Lock()
+	defer s.sanctionsMu.Unlock()
+	swept := 0
+	for userID, sanction := range s.sanctions {
+		if sanction.ExpiresAt != nil && !sanction.ExpiresAt.After(now) {
+			delete(s.sanctions, userID)
+			swept++
+		}
+	}
+	return swept, nil
+}
+
+func (s *InMemoryStorage) BlockUser(ctx context.Context, postID, userID string) error {
+	sh := s.postShard(postID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, exists := sh.posts[postID]; !exists {
+		return fmt.Errorf("post not found")
+	}
+	if sh.blocks[postID] == nil {
+		sh.blocks[postID] = make(map[string]bool)
+	}
+	sh.blocks[postID][userID] = true
+	return nil
+}
+
+func (s *InMemoryStorage) UnblockUser(ctx context.Context, postID, userID string) (bool, error) {
+	sh := s.postShard(postID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	blocked := sh.blocks[postID][userID]
+	delete(sh.blocks[postID], userID)
+	return blocked, nil
+}
+
+func (s *InMemoryStorage) GetRevisions(ctx context.Context, itemID string) ([]*models.Revision, error) {
+	sh, ok := s.commentShard(itemID)
+	if !ok {
+		sh = s.postShard(itemID)
+	}
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	revisions := make([]*models.Revision, len(sh.revisions[itemID]))
+	for i, r := range sh.revisions[itemID] {
+		c := *r
+		revisions[i] = &c
+	}
+	return revisions, nil
+}
+
+// addRevision records text as the next revision of itemID. The caller holds
+// the shard's write lock.
+func (sh *memoryShard) addRevision(itemID, text string) {
+	n := len(sh.revisions[itemID]) + 1
+	sh.revisions[itemID] = append(sh.revisions[itemID], &models.Revision{Number: n, Text: text, CreatedAt: time.Now()})
+}
 
-	return newComment, nil
+func (sh *memoryShard) indexComment(comment *models.CommentResponse, seq uint64) {
+	sh.comments[comment.ID] = comment
+	sh.commentList = append(sh.commentList, seqComment{seq: seq, comment: comment})
+	sh.commentsByPost[comment.PostID] = append(sh.commentsByPost[comment.PostID], comment)
+	if comment.ParentCommentID != nil {
+		sh.commentsByParent[*comment.ParentCommentID] = append(sh.commentsByParent[*comment.ParentCommentID], comment)
+	} else {
+		sh.rootsByPost[comment.PostID] = append(sh.rootsByPost[comment.PostID], comment)
+	}
+}
+
+// mergeBySeq merges per-shard lists, each already ordered by sequence, and
+// returns the requested page of the combined order. The lists are snapshots
+// taken under each shard's lock; valueOf receives the index of the entry's
+// list so it can lock that shard again while copying the item.
+func mergeBySeq[E any, T any](lists [][]E, seqOf func(E) uint64, valueOf func(int, E) T, limit, offset *int) []T {
+	total := 0
+	var maxSeq uint64
+	for _, list := range lists {
+		total += len(list)
+		if len(list) > 0 && seqOf(list[len(list)-1]) > maxSeq {
+			maxSeq = seqOf(list[len(list)-1])
+		}
+	}
+	start, end := window(total, limit, offset)
+	result := make([]T, 0, end-start)
+	if start == end {
+		return result
+	}
+
+	// Binary search for the sequence number of the first item on the page,
+	// so the merge skips the offset instead of walking through it.
+	below := func(seq uint64) []int {
+		positions := make([]int, len(lists))
+		for i, list := range lists {
+			positions[i] = sort.Search(len(list), func(j int) bool { return seqOf(list[j]) >= seq })
+		}
+		return positions
+	}
+	count := func(positions []int) int {
+		n := 0
+		for _, p := range positions {
+			n += p
+		}
+		return n
+	}
+	first := sort.Search(int(maxSeq)+1, func(seq int) bool {
+		return count(below(uint64(seq)+1)) > start
+	})
+	positions := below(uint64(first))
+
+	h := &seqHeap{}
+	for i, list := range lists {
+		if positions[i] < len(list) {
+			h.items = append(h.items, seqCursor{list: i, pos: positions[i], seq: seqOf(list[positions[i]])})
+		}
+	}
+	heap.Init(h)
+
+	for n := start; n < end; n++ {
+		cur := h.items[0]
+		result = append(result, valueOf(cur.list, lists[cur.list][cur.pos]))
+		cur.pos++
+		if cur.pos < len(lists[cur.list]) {
+			cur.seq = seqOf(lists[cur.list][cur.pos])
+			h.items[0] = cur
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return result
+}
+
+type seqCursor struct {
+	list int
+	pos  int
+	seq  uint64
+}
+
+type seqHeap struct {
+	items []seqCursor
+}
+
+func (h *seqHeap) Len() int           { return len(h.items) }
+func (h *seqHeap) Less(i, j int) bool { return h.items[i].seq < h.items[j].seq }
+func (h *seqHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *seqHeap) Push(x any)         { h.items = append(h.items, x.(seqCursor)) }
+func (h *seqHeap) Pop() any {
+	old := h.items
+	item := old[len(old)-1]
+	h.items = old[:len(old)-1]
+	return item
+}
+
+func (s *InMemoryStorage) ClaimIdempotencyKey(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	s.idempotencyMu.Lock()
+	defer s.idempotencyMu.Unlock()
+	if held, ok := s.idempotencyKeys[record.Key]; ok && held.ExpiresAt.After(time.Now()) {
+		c := *held
+		return &c, nil
+	}
+	c := *record
+	s.idempotencyKeys[record.Key] = &c
+	return nil, nil
+}
+
+func (s *InMemoryStorage) CompleteIdempotencyKey(ctx context.Context, key string, result []byte, expiresAt time.Time) error {
+	s.idempotencyMu.Lock()
+	defer s.idempotencyMu.Unlock()
+	if held, ok := s.idempotencyKeys[key]; ok {
+		held.Result, held.ExpiresAt = result, expiresAt
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	s.idempotencyMu.Lock()
+	defer s.idempotencyMu.Unlock()
+	if held, ok := s.idempotencyKeys[key]; ok && held.Result == nil {
+		delete(s.idempotencyKeys, key)
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) SweepIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	s.idempotencyMu.Lock()
+	defer s.idempotencyMu.Unlock()
+	swept := 0
+	for key, record := range s.idempotencyKeys {
+		if !record.ExpiresAt.After(now) {
+			delete(s.idempotencyKeys, key)
+			swept++
+		}
+	}
+	return swept, nil
+}
+
+func (s *InMemoryStorage) AppendAudit(ctx context.Context, entry *models.AuditEntry) error {
+	c := *entry
+	s.auditMu.Lock()
+	s.audit = append(s.audit, &c)
+	s.auditMu.Unlock()
+	return nil
+}
+
+func (s *InMemoryStorage) GetAuditLog(ctx context.Context, filter *models.AuditFilter, first int, after string) (*models.AuditLogPage, error) {
+	afterSeq, err := parseQueueCursor(after)
+	if err != nil {
+		return nil, err
+	}
+	s.auditMu.RLock()
+	defer s.auditMu.RUnlock()
+	end := uint64(len(s.audit))
+	if afterSeq > 0 && afterSeq-1 < end {
+		end = afterSeq - 1
+	}
+	var entries []*models.AuditEntry
+	var seqs []uint64
+	for i := int(end) - 1; i >= 0 && len(entries) <= first; i-- {
+		if matchesAudit(s.audit[i], filter) {
+			c := *s.audit[i]
+			entries = append(entries, &c)
+			seqs = append(seqs, uint64(i)+1)
+		}
+	}
+	return auditPage(entries, seqs, first), nil
 }
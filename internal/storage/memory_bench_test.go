@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+)
+
+const (
+	benchPosts           = 10_000
+	benchTotalComments   = 1_000_000
+	benchCommentsPerPost = benchTotalComments / benchPosts
+)
+
+var (
+	benchMemoryOnce    sync.Once
+	benchMemory        *InMemoryStorage
+	benchMemoryParents []string
+)
+
+// benchMemoryStorage builds a shared storage with 1M comments: every post
+// gets 100 comments, half of them replies to the post's first comment.
+func benchMemoryStorage(b *testing.B) *InMemoryStorage {
+	b.Helper()
+	benchMemoryOnce.Do(func() {
+		ctx := context.Background()
+		s := NewMemoryStorage()
+		for p := 0; p < benchPosts; p++ {
+			postID := "post-" + strconv.Itoa(p)
+			if _, err := s.CreatePost(ctx, postID, "post", true, "author", models.ModerationNone); err != nil {
+				panic(err)
+			}
+			parent, err := s.CreateComment(ctx, "comment", postID, "author")
+			if err != nil {
+				panic(err)
+			}
+			benchMemoryParents = append(benchMemoryParents, parent.ID)
+			for c := 1; c < benchCommentsPerPost; c++ {
+				itemID := postID
+				if c%2 == 0 {
+					itemID = parent.ID
+				}
+				if _, err := s.CreateComment(ctx, "comment", itemID, "author"); err != nil {
+					panic(err)
+				}
+			}
+		}
+		benchMemory = s
+	})
+	return benchMemory
+}
+
+func BenchmarkInMemoryGetCommentsByPostID(b *testing.B) {
+	s := benchMemoryStorage(b)
+	ctx := context.Background()
+	limit, offset := 20, 40
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		postID := "post-" + strconv.Itoa(i%benchPosts)
+		if _, err := s.GetCommentsByPostID(ctx, postID, &limit, &offset); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInMemoryGetCommentsByParentID(b *testing.B) {
+	s := benchMemoryStorage(b)
+	ctx := context.Background()
+	limit := 20
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parentID := benchMemoryParents[i%len(benchMemoryParents)]
+		if _, err := s.GetCommentsByParentID(ctx, parentID, &limit, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInMemoryGetAllComments(b *testing.B) {
+	s := benchMemoryStorage(b)
+	ctx := context.Background()
+	limit, offset := 50, benchTotalComments/2
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.GetAllComments(ctx, &limit, &offset); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInMemoryCreateComment(b *testing.B) {
+	s := benchMemoryStorage(b)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		postID := "post-" + strconv.Itoa(i%benchPosts)
+		if _, err := s.CreateComment(ctx, "comment", postID, "author"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInMemoryParallelCreateComment(b *testing.B) {
+	s := benchMemoryStorage(b)
+	ctx := context.Background()
+	var n atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			postID := "post-" + strconv.Itoa(int(n.Add(1)%benchPosts))
+			if _, err := s.CreateComment(ctx, "comment", postID, "author"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkInMemoryParallelMixed(b *testing.B) {
+	s := benchMemoryStorage(b)
+	ctx := context.Background()
+	limit := 20
+	var n atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := n.Add(1)
+			postID := "post-" + strconv.Itoa(int(i%benchPosts))
+			var err error
+			if i%10 == 0 {
+				_, err = s.CreateComment(ctx, "comment", postID, "author")
+			} else {
+				_, err = s.GetCommentsByPostID(ctx, postID, &limit, nil)
+			}
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
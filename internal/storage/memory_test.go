@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func intPtr(i int) *int { return &i }
+
+func TestInMemoryStorage_PaginationIsOrdered(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "p1", "post", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+
+	var ids []string
+	for i := 0; i < 10; i++ {
+		c, err := s.CreateComment(ctx, strconv.Itoa(i), "p1", "author")
+		require.NoError(t, err)
+		ids = append(ids, c.ID)
+	}
+
+	for i := 0; i < 3; i++ {
+		got, err := s.GetCommentsByPostID(ctx, "p1", intPtr(3), intPtr(4))
+		require.NoError(t, err)
+		require.Len(t, got, 3)
+		assert.Equal(t, []string{ids[4], ids[5], ids[6]}, []string{got[0].ID, got[1].ID, got[2].ID})
+	}
+
+	got, err := s.GetAllComments(ctx, nil, intPtr(8))
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	got, err = s.GetAllComments(ctx, intPtr(5), intPtr(20))
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestInMemoryStorage_RepliesIndex(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "p1", "post", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+	parent, err := s.CreateComment(ctx, "parent", "p1", "author")
+	require.NoError(t, err)
+	first, err := s.CreateComment(ctx, "first", parent.ID, "author")
+	require.NoError(t, err)
+	second, err := s.CreateComment(ctx, "second", parent.ID, "author")
+	require.NoError(t, err)
+
+	replies, err := s.GetCommentsByParentID(ctx, parent.ID, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, replies, 2)
+	assert.Equal(t, first.ID, replies[0].ID)
+	assert.Equal(t, second.ID, replies[1].ID)
+
+	tree, err := s.GetCommentTree(ctx, "p1", 0, 1)
+	require.NoError(t, err)
+	require.Len(t, tree, 1)
+	require.Len(t, tree[0].Replies, 1)
+	assert.Equal(t, first.ID, tree[0].Replies[0].ID)
+}
+
+func TestInMemoryStorage_CreatePostReplaces(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "p1", "first", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "p2", "other", true, "author", models.ModerationNone)
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "p1", "second", false, "author", models.ModerationNone)
+	require.NoError(t, err)
+
+	posts, err := s.GetAllPosts(ctx, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, "p1", posts[0].ID)
+	assert.Equal(t, "second", posts[0].TextPost)
+	assert.False(t, posts[0].Commentable)
+
+	revisions, err := s.GetRevisions(ctx, "p1")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, "second", revisions[0].Text)
+}
+
+func TestInMemoryStorage_ConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	const posts, writers, perWriter = 16, 8, 200
+
+	for p := 0; p < posts; p++ {
+		_, err := s.CreatePost(ctx, "p"+strconv.Itoa(p), "post", p%4 != 0, "author", models.ModerationNone)
+		require.NoError(t, err)
+	}
+
+	var g errgroup.Group
+	var created atomic.Int64
+	for w := 0; w < writers; w++ {
+		g.Go(func() error {
+			var last string
+			for i := 0; i < perWriter; i++ {
+				p := (w + i) % posts
+				itemID, closed := "p"+strconv.Itoa(p), p%4 == 0
+				if last != "" && i%3 == 0 {
+					itemID, closed = last, false
+				}
+				c, err := s.CreateComment(ctx, "text", itemID, "author")
+				if closed {
+					if !errors.Is(err, ErrNotCommentable) {
+						return fmt.Errorf("comment on %s with comments turned off: got %v, want ErrNotCommentable", itemID, err)
+					}
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("comment on %s: %w", itemID, err)
+				}
+				created.Add(1)
+				last = c.ID
+			}
+			return nil
+		})
+		g.Go(func() error {
+			for i := 0; i < perWriter; i++ {
+				postID := "p" + strconv.Itoa(i%posts)
+				if _, err := s.GetAllComments(ctx, intPtr(10), intPtr(i)); err != nil {
+					return err
+				}
+				if _, err := s.GetCommentsByPostID(ctx, postID, nil, nil); err != nil {
+					return err
+				}
+				if _, err := s.GetCommentTree(ctx, postID, 0, 0); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	require.NoError(t, g.Wait())
+
+	all, err := s.GetAllComments(ctx, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, all, int(created.Load()))
+
+	for p := 0; p < posts; p += 4 {
+		byPost, err := s.GetCommentsByPostID(ctx, "p"+strconv.Itoa(p), nil, nil)
+		require.NoError(t, err)
+		assert.Empty(t, byPost, "post %d has comments turned off", p)
+	}
+}
+
+func TestInMemoryStorage_SweepSanctions(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	expired := time.Now().Add(-time.Minute)
+	_, err := s.BanUser(ctx, &models.Sanction{UserID: "bob", Kind: models.SanctionKindBan, ExpiresAt: &expired})
+	require.NoError(t, err)
+	_, err = s.BanUser(ctx, &models.Sanction{UserID: "eve", Kind: models.SanctionKindBan})
+	require.NoError(t, err)
+
+	// An expired ban is not enforced even before the sweep removes it.
+	require.NoError(t, s.CheckSanction(ctx, "bob", false))
+
+	n, err := s.SweepSanctions(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.ErrorIs(t, s.CheckSanction(ctx, "eve", false), ErrBanned)
+}
+
+func TestInMemoryStorage_AuditLogFilter(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, actor := range []string{"alice", "bob", "alice", "bob"} {
+		target := "post" + strconv.Itoa(i%2)
+		err := s.AppendAudit(ctx, &models.AuditEntry{ID: strconv.Itoa(i), At: start.Add(time.Duration(i) * time.Hour), Actor: &actor, Action: "updatePost", TargetID: &target})
+		require.NoError(t, err)
+	}
+
+	ids := func(filter *models.AuditFilter) []string {
+		page, err := s.GetAuditLog(ctx, filter, 10, "")
+		require.NoError(t, err)
+		var ids []string
+		for _, e := range page.Entries {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+	actor, target := "alice", "post1"
+	since, until := start.Add(time.Hour), start.Add(3*time.Hour)
+	assert.Equal(t, []string{"3", "2", "1", "0"}, ids(nil))
+	assert.Equal(t, []string{"2", "0"}, ids(&models.AuditFilter{Actor: &actor}))
+	assert.Equal(t, []string{"3", "1"}, ids(&models.AuditFilter{TargetID: &target}))
+	assert.Equal(t, []string{"2", "1"}, ids(&models.AuditFilter{Since: &since, Until: &until}))
+
+	_, err := s.GetAuditLog(ctx, nil, 10, "nope")
+	assert.Error(t, err)
+}
+
+func TestInMemoryStorage_UpdateVersion(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	_, err := s.CreatePost(ctx, "post1", "post", true, "alice", models.ModerationNone)
+	require.NoError(t, err)
+
+	// Of concurrent updates from the same version exactly one wins.
+	var wg sync.WaitGroup
+	var won, conflicts atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.UpdatePost(ctx, "post1", strconv.Itoa(i), 1)
+			if err == nil {
+				won.Add(1)
+			} else if errors.Is(err, ErrConflict) {
+				conflicts.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), won.Load())
+	assert.Equal(t, int32(9), conflicts.Load())
+
+	_, err = s.UpdatePost(ctx, "post1", "again", 1)
+	var conflict *ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 2, conflict.Current)
+	post, err := s.UpdatePost(ctx, "post1", "again", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, post.Version)
+}
+
+func TestInMemoryStorage_GetByIDs(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	for _, id := range []string{"post1", "post2"} {
+		_, err := s.CreatePost(ctx, id, "post", true, "alice", models.ModerationNone)
+		require.NoError(t, err)
+	}
+	comment, err := s.CreateComment(ctx, "comment", "post2", "bob")
+	require.NoError(t, err)
+
+	posts, err := s.GetPostsByIDs(ctx, []string{"post2", "missing", "post1"})
+	require.NoError(t, err)
+	assert.Len(t, posts, 2)
+	assert.Equal(t, "post1", posts["post1"].ID)
+	assert.Equal(t, "post2", posts["post2"].ID)
+
+	comments, err := s.GetCommentsByIDs(ctx, []string{comment.ID, "missing"})
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, "comment", comments[comment.ID].TextComment)
+}
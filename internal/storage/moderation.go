@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+)
+
+var ErrNotQueued = errors.New("comment is not awaiting moderation")
+
+// moderatedStatus returns the status a new comment starts with under the
+// post's moderation mode and whether it goes to the moderation queue.
+func moderatedStatus(moderation models.Moderation) (models.Status, bool) {
+	switch moderation {
+	case models.ModerationPre:
+		return models.StatusPending, true
+	case models.ModerationPost:
+		return models.StatusVisible, true
+	default:
+		return models.StatusVisible, false
+	}
+}
+
+// Queue cursors are the insertion sequence of the last comment on a page.
+func parseQueueCursor(after string) (uint64, error) {
+	if after == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseUint(after, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	return seq, nil
+}
+
+// queuePage turns up to first+1 queued comments, ordered by their sequence
+// numbers, into a page of at most first.
+func queuePage(comments []*models.CommentResponse, seqs []uint64, first int) *models.ModerationQueue {
+	page := &models.ModerationQueue{Comments: comments}
+	if len(comments) > first {
+		page.Comments = comments[:first]
+		page.HasNextPage = true
+	}
+	if n := len(page.Comments); n > 0 {
+		cursor := strconv.FormatUint(seqs[n-1], 10)
+		page.EndCursor = &cursor
+	}
+	if page.Comments == nil {
+		page.Comments = []*models.CommentResponse{}
+	}
+	return page
+}
+
+// sortReportGroups orders groups by report count, then by the most recent
+// report, so the items that need attention most come first.
+func sortReportGroups(groups []*models.ReportGroup) {
+	latest := func(g *models.ReportGroup) time.Time {
+		return g.Reports[len(g.Reports)-1].CreatedAt
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return latest(groups[i]).After(latest(groups[j]))
+	})
+}
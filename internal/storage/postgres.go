@@ -2,269 +2,1058 @@ package storage
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"time"
 
 	"github.com/NGerasimovvv/GraphQL/internal/config"
 	"github.com/NGerasimovvv/GraphQL/internal/models"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const schemaVersion = 11
+
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS post (
+		id UUID PRIMARY KEY,
+		text TEXT NOT NULL,
+		authorPost VARCHAR(50) NOT NULL,
+		commentable BOOLEAN NOT NULL);`,
+	`CREATE TABLE IF NOT EXISTS comment (
+		id UUID PRIMARY KEY,
+		comment VARCHAR(2000),
+		authorComment VARCHAR(50) NOT NULL,
+		post_id UUID NOT NULL,
+		parent_comment_id UUID,
+		FOREIGN KEY (post_id) REFERENCES post(id),
+		FOREIGN KEY (parent_comment_id) REFERENCES comment(id)
+	);`,
+	`CREATE INDEX IF NOT EXISTS comment_post_id_idx ON comment (post_id);`,
+	`CREATE INDEX IF NOT EXISTS comment_parent_comment_id_idx ON comment (parent_comment_id);`,
+	`CREATE TABLE IF NOT EXISTS schema_version (version INT NOT NULL);`,
+
+	// path is a materialized path of zero-padded insertion numbers, so
+	// ORDER BY path yields a depth-first, oldest-first walk of a thread.
+	`ALTER TABLE comment ADD COLUMN IF NOT EXISTS seq BIGSERIAL;`,
+	`ALTER TABLE comment ADD COLUMN IF NOT EXISTS path TEXT;`,
+	`WITH RECURSIVE p AS (
+		SELECT id, lpad(seq::text, 19, '0') || '/' AS path FROM comment WHERE parent_comment_id IS NULL
+		UNION ALL
+		SELECT c.id, p.path || lpad(c.seq::text, 19, '0') || '/' FROM comment c JOIN p ON c.parent_comment_id = p.id
+	)
+	UPDATE comment SET path = p.path FROM p WHERE comment.id = p.id AND comment.path IS NULL;`,
+	`CREATE OR REPLACE FUNCTION comment_set_path() RETURNS trigger AS $$
+	BEGIN
+		NEW.path := COALESCE((SELECT path FROM comment WHERE id = NEW.parent_comment_id), '') || lpad(NEW.seq::text, 19, '0') || '/';
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;`,
+	`DROP TRIGGER IF EXISTS comment_path ON comment;`,
+	`CREATE TRIGGER comment_path BEFORE INSERT ON comment FOR EACH ROW EXECUTE FUNCTION comment_set_path();`,
+	`CREATE INDEX IF NOT EXISTS comment_post_id_path_idx ON comment (post_id, path);`,
+
+	`ALTER TABLE post ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'VISIBLE';`,
+	`ALTER TABLE comment ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'VISIBLE';`,
+
+	`ALTER TABLE post ADD COLUMN IF NOT EXISTS moderation VARCHAR(8) NOT NULL DEFAULT 'NONE';`,
+	`ALTER TABLE comment ADD COLUMN IF NOT EXISTS queued BOOLEAN NOT NULL DEFAULT false;`,
+	`CREATE INDEX IF NOT EXISTS comment_queue_idx ON comment (seq) WHERE queued;`,
+
+	`CREATE TABLE IF NOT EXISTS report (
+		id UUID PRIMARY KEY,
+		item_id UUID NOT NULL,
+		item_type VARCHAR(8) NOT NULL,
+		reporter VARCHAR(50) NOT NULL,
+		reason VARCHAR(16) NOT NULL,
+		note VARCHAR(2000),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		UNIQUE (item_id, reporter)
+	);`,
+
+	`CREATE TABLE IF NOT EXISTS user_sanction (
+		user_id VARCHAR(50) PRIMARY KEY,
+		kind VARCHAR(8) NOT NULL,
+		reason VARCHAR(2000),
+		created_by VARCHAR(50) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ
+	);`,
+	`CREATE TABLE IF NOT EXISTS post_block (
+		post_id UUID NOT NULL REFERENCES post(id),
+		user_id VARCHAR(50) NOT NULL,
+		PRIMARY KEY (post_id, user_id)
+	);`,
+
+	// The audit log is append-only: a trigger rejects updates and deletes.
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		seq BIGSERIAL PRIMARY KEY,
+		id UUID NOT NULL UNIQUE,
+		at TIMESTAMPTZ NOT NULL,
+		actor VARCHAR(50),
+		actor_role VARCHAR(16),
+		action VARCHAR(64) NOT NULL,
+		target_type VARCHAR(16),
+		target_id VARCHAR(50),
+		request_id VARCHAR(64),
+		before JSONB,
+		after JSONB,
+		diff JSONB NOT NULL,
+		error TEXT
+	);`,
+	`CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor, seq);`,
+	`CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target_id, seq);`,
+	`CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_log is append-only';
+	END;
+	$$ LANGUAGE plpgsql;`,
+	`DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;`,
+	`CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();`,
+
+	// Triggers record a revision whenever a text is inserted or changed, so
+	// bulk loads and edits are covered alike.
+	`ALTER TABLE post ADD COLUMN IF NOT EXISTS edited BOOLEAN NOT NULL DEFAULT false;`,
+	`ALTER TABLE comment ADD COLUMN IF NOT EXISTS edited BOOLEAN NOT NULL DEFAULT false;`,
+	`CREATE TABLE IF NOT EXISTS revision (
+		item_id UUID NOT NULL,
+		number INT NOT NULL,
+		text TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (item_id, number)
+	);`,
+	`INSERT INTO revision (item_id, number, text)
+	SELECT id, 1, text FROM post UNION ALL SELECT id, 1, COALESCE(comment, '') FROM comment
+	ON CONFLICT DO NOTHING;`,
+	// Concurrent edits of one item take their revision numbers in turn: the
+	// lock is held to the end of the transaction, and the next statement
+	// sees the revision the previous holder committed.
+	`CREATE OR REPLACE FUNCTION add_revision(item UUID, body TEXT) RETURNS void AS $$
+	BEGIN
+		PERFORM pg_advisory_xact_lock(hashtext('revision:' || item::text));
+		INSERT INTO revision (item_id, number, text)
+		SELECT item, COALESCE(max(number), 0) + 1, body FROM revision WHERE item_id = item;
+	END;
+	$$ LANGUAGE plpgsql;`,
+	`CREATE OR REPLACE FUNCTION post_revision() RETURNS trigger AS $$
+	BEGIN
+		PERFORM add_revision(NEW.id, NEW.text);
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;`,
+	`CREATE OR REPLACE FUNCTION comment_revision() RETURNS trigger AS $$
+	BEGIN
+		PERFORM add_revision(NEW.id, COALESCE(NEW.comment, ''));
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;`,
+	`DROP TRIGGER IF EXISTS post_revision ON post;`,
+	`DROP TRIGGER IF EXISTS post_edit ON post;`,
+	`DROP TRIGGER IF EXISTS comment_revision ON comment;`,
+	`DROP TRIGGER IF EXISTS comment_edit ON comment;`,
+	`CREATE TRIGGER post_revision AFTER INSERT ON post FOR EACH ROW EXECUTE FUNCTION post_revision();`,
+	`CREATE TRIGGER post_edit AFTER UPDATE OF text ON post FOR EACH ROW
+		WHEN (OLD.text IS DISTINCT FROM NEW.text) EXECUTE FUNCTION post_revision();`,
+	`CREATE TRIGGER comment_revision AFTER INSERT ON comment FOR EACH ROW EXECUTE FUNCTION comment_revision();`,
+	`CREATE TRIGGER comment_edit AFTER UPDATE OF comment ON comment FOR EACH ROW
+		WHEN (OLD.comment IS DISTINCT FROM NEW.comment) EXECUTE FUNCTION comment_revision();`,
+
+	`CREATE TABLE IF NOT EXISTS idempotency_key (
+		key TEXT PRIMARY KEY,
+		fingerprint TEXT NOT NULL,
+		result BYTEA,
+		expires_at TIMESTAMPTZ NOT NULL
+	);`,
+
+	`ALTER TABLE post ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`,
+	`ALTER TABLE comment ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`,
+
+	// Lists are returned oldest first, in insertion order like the memory
+	// storage; comments already have seq.
+	`ALTER TABLE post ADD COLUMN IF NOT EXISTS seq BIGSERIAL;`,
+	`CREATE INDEX IF NOT EXISTS post_seq_idx ON post (seq);`,
+}
+
+// Prepared statement names. Every pooled connection prepares them on connect,
+// so methods pass the name instead of the SQL text.
+const (
+	stmtAllPosts         = "all_posts"
+	stmtPostByID         = "post_by_id"
+	stmtPostsByIDs       = "posts_by_ids"
+	stmtInsertPost       = "insert_post"
+	stmtAllComments      = "all_comments"
+	stmtCommentsByPost   = "comments_by_post"
+	stmtCommentsByParent = "comments_by_parent"
+	stmtCommentByID      = "comment_by_id"
+	stmtCommentsByIDs    = "comments_by_ids"
+	stmtPostCommentable  = "post_commentable"
+	stmtUpdatePost       = "update_post"
+	stmtUpdateComment    = "update_comment"
+	stmtSetPostStatus    = "set_post_status"
+	stmtSetCommentStatus = "set_comment_status"
+	stmtPostVersion      = "post_version"
+	stmtCommentVersion   = "comment_version"
+	stmtCommentPostID    = "comment_post_id"
+	stmtInsertComment    = "insert_comment"
+	stmtSchemaVersion    = "schema_version"
+	stmtCommentTree      = "comment_tree"
+	stmtModerationQueue  = "moderation_queue"
+	stmtReviewComment    = "review_comment"
+	stmtQueueComment     = "queue_comment"
+	stmtItemType         = "item_type"
+	stmtInsertReport     = "insert_report"
+	stmtReportCount      = "report_count"
+	stmtReportGroups     = "report_groups"
+	stmtReportsByItems   = "reports_by_items"
+	stmtSanction         = "sanction"
+	stmtUpsertSanction   = "upsert_sanction"
+	stmtDeleteSanction   = "delete_sanction"
+	stmtSweepSanctions   = "sweep_sanctions"
+	stmtIsBlocked        = "is_blocked"
+	stmtInsertBlock      = "insert_block"
+	stmtDeleteBlock      = "delete_block"
+	stmtRevisions        = "revisions"
+	stmtClaimIdemKey     = "claim_idempotency_key"
+	stmtIdemKey          = "idempotency_key"
+	stmtCompleteIdemKey  = "complete_idempotency_key"
+	stmtReleaseIdemKey   = "release_idempotency_key"
+	stmtSweepIdemKeys    = "sweep_idempotency_keys"
+	stmtInsertAudit      = "insert_audit"
+	stmtAuditLog         = "audit_log"
+)
+
+const (
+	postColumns            = "id, text, authorPost, commentable, moderation, status, edited, version"
+	commentColumns         = "id, comment, authorComment, post_id, parent_comment_id, status, edited, version"
+	reportColumns          = "id, item_id, item_type, reporter, reason, note, created_at"
+	auditColumns           = "id, at, actor, actor_role, action, target_type, target_id, request_id, before, after, diff, error"
+	paginationPlaceholders = " LIMIT $%d OFFSET $%d"
+)
+
+// LIMIT NULL means no limit and OFFSET NULL means no offset, so one statement
+// serves every combination of optional pagination arguments.
+var statements = map[string]string{
+	stmtAllPosts:         "SELECT " + postColumns + " FROM post ORDER BY seq" + fmt.Sprintf(paginationPlaceholders, 1, 2),
+	stmtPostByID:         "SELECT " + postColumns + " FROM post WHERE id=$1",
+	stmtPostsByIDs:       "SELECT " + postColumns + " FROM post WHERE id = ANY($1)",
+	stmtInsertPost:       "INSERT INTO post (id, text, authorPost, commentable, moderation) VALUES ($1, $2, $3, $4, $5) RETURNING " + postColumns,
+	stmtAllComments:      "SELECT " + commentColumns + " FROM comment ORDER BY seq" + fmt.Sprintf(paginationPlaceholders, 1, 2),
+	stmtCommentsByPost:   "SELECT " + commentColumns + " FROM comment WHERE post_id=$1 ORDER BY seq" + fmt.Sprintf(paginationPlaceholders, 2, 3),
+	stmtCommentsByParent: "SELECT " + commentColumns + " FROM comment WHERE parent_comment_id=$1 ORDER BY seq" + fmt.Sprintf(paginationPlaceholders, 2, 3),
+	stmtCommentByID:      "SELECT " + commentColumns + " FROM comment WHERE id=$1",
+	stmtCommentsByIDs:    "SELECT " + commentColumns + " FROM comment WHERE id = ANY($1)",
+	stmtPostCommentable:  "SELECT commentable, status, moderation FROM post WHERE id=$1",
+	stmtCommentPostID:    "SELECT c.post_id, c.status, p.moderation FROM comment c JOIN post p ON p.id = c.post_id WHERE c.id=$1",
+	stmtUpdatePost:       "UPDATE post SET edited = edited OR text IS DISTINCT FROM $2, text=$2, version=version+1 WHERE id=$1 AND version=$3 RETURNING " + postColumns,
+	stmtUpdateComment:    "UPDATE comment SET edited = edited OR comment IS DISTINCT FROM $2, comment=$2, version=version+1 WHERE id=$1 AND version=$3 RETURNING " + commentColumns,
+	stmtSetPostStatus:    "UPDATE post SET status=$2, version=version+1 WHERE id=$1 RETURNING " + postColumns,
+	stmtSetCommentStatus: "UPDATE comment SET status=$2, version=version+1 WHERE id=$1 RETURNING " + commentColumns,
+	stmtPostVersion:      "SELECT version FROM post WHERE id=$1",
+	stmtCommentVersion:   "SELECT version FROM comment WHERE id=$1",
+	stmtInsertComment:    "INSERT INTO comment (id, comment, authorComment, post_id, parent_comment_id, status, queued) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING " + commentColumns,
+	stmtModerationQueue:  "SELECT " + commentColumns + ", seq FROM comment WHERE queued AND seq > $1 ORDER BY seq LIMIT $2",
+	stmtQueueComment:     "UPDATE comment SET queued=true WHERE id=$1",
+	stmtReviewComment:    "UPDATE comment SET status=$2, queued=false, version=version+1 WHERE id=$1 AND queued RETURNING " + commentColumns,
+	stmtItemType:         "SELECT 'POST' FROM post WHERE id=$1 UNION ALL SELECT 'COMMENT' FROM comment WHERE id=$1",
+	stmtInsertReport:     "INSERT INTO report (" + reportColumns + ") VALUES ($1, $2, $3, $4, $5, $6, now()) ON CONFLICT (item_id, reporter) DO NOTHING RETURNING created_at",
+	stmtReportCount:      "SELECT count(*) FROM report WHERE item_id=$1",
+	stmtReportGroups:     "SELECT item_id FROM report GROUP BY item_id ORDER BY count(*) DESC, max(created_at) DESC" + fmt.Sprintf(paginationPlaceholders, 1, 2),
+	stmtReportsByItems:   "SELECT " + reportColumns + " FROM report WHERE item_id = ANY($1) ORDER BY created_at",
+	stmtSanction:         "SELECT kind, expires_at FROM user_sanction WHERE user_id=$1",
+	stmtUpsertSanction:   "INSERT INTO user_sanction (user_id, kind, reason, created_by, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (user_id) DO UPDATE SET kind=EXCLUDED.kind, reason=EXCLUDED.reason, created_by=EXCLUDED.created_by, created_at=EXCLUDED.created_at, expires_at=EXCLUDED.expires_at",
+	stmtDeleteSanction:   "DELETE FROM user_sanction WHERE user_id=$1",
+	stmtSweepSanctions:   "DELETE FROM user_sanction WHERE expires_at <= $1",
+	stmtIsBlocked:        "SELECT EXISTS (SELECT 1 FROM post_block WHERE post_id=$1 AND user_id=$2)",
+	stmtInsertBlock:      "INSERT INTO post_block (post_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+	stmtDeleteBlock:      "DELETE FROM post_block WHERE post_id=$1 AND user_id=$2",
+	stmtInsertAudit:      "INSERT INTO audit_log (" + auditColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+	stmtAuditLog:         "SELECT " + auditColumns + ", seq FROM audit_log WHERE ($1::bigint = 0 OR seq < $1) AND ($2::text IS NULL OR actor = $2) AND ($3::text IS NULL OR action = $3) AND ($4::text IS NULL OR target_id = $4) AND ($5::timestamptz IS NULL OR at >= $5) AND ($6::timestamptz IS NULL OR at < $6) ORDER BY seq DESC LIMIT $7",
+	stmtRevisions:        "SELECT number, text, created_at FROM revision WHERE item_id=$1 ORDER BY number",
+	stmtClaimIdemKey:     "INSERT INTO idempotency_key (key, fingerprint, expires_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO UPDATE SET fingerprint=EXCLUDED.fingerprint, result=NULL, expires_at=EXCLUDED.expires_at WHERE idempotency_key.expires_at <= $4 RETURNING key",
+	stmtIdemKey:          "SELECT key, fingerprint, result, expires_at FROM idempotency_key WHERE key=$1 AND expires_at > $2",
+	stmtCompleteIdemKey:  "UPDATE idempotency_key SET result=$2, expires_at=$3 WHERE key=$1",
+	stmtReleaseIdemKey:   "DELETE FROM idempotency_key WHERE key=$1 AND result IS NULL",
+	stmtSweepIdemKeys:    "DELETE FROM idempotency_key WHERE expires_at <= $1",
+	stmtSchemaVersion:    "SELECT version FROM schema_version",
+	stmtCommentTree: `
+	WITH RECURSIVE tree AS (
+		SELECT * FROM (
+			SELECT ` + commentColumns + `, path, 1 AS depth
+			FROM comment WHERE post_id = $1 AND parent_comment_id IS NULL
+			ORDER BY path LIMIT $3
+		) roots
+		UNION ALL
+		SELECT r.id, r.comment, r.authorComment, r.post_id, r.parent_comment_id, r.status, r.edited, r.version, r.path, tree.depth + 1
+		FROM tree CROSS JOIN LATERAL (
+			SELECT ` + commentColumns + `, path
+			FROM comment WHERE parent_comment_id = tree.id
+			ORDER BY path LIMIT $3
+		) r
+		WHERE tree.depth < $2
+	)
+	SELECT ` + commentColumns + ` FROM tree ORDER BY path`,
+}
+
 type PostgresStorage struct {
-	DB *sql.DB
+	// Pool is the primary. Writes always go here, reads only when no replica
+	// is healthy or the request has already written.
+	Pool        *pgxpool.Pool
+	replicas    *replicaSet
+	readRetries int
 }
 
 func InitPostgresDatabase(cfg *config.Config) *PostgresStorage {
 	const op = "postgres.InitPostgresDatabase"
-	dbHost := cfg.Postgres.PostgresHost
-	dbPort := cfg.Postgres.PostgresPort
-	dbUser := cfg.Postgres.PostgresUser
-	dbPasswd := cfg.Postgres.PostgresPassword
-	dbName := cfg.Postgres.DatabaseName
-
-	postgresUrl := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", dbHost, dbPort, dbUser, dbPasswd, dbName)
-	db, err := sql.Open("postgres", postgresUrl)
+	poolConfig, err := pgxpool.ParseConfig(cfg.Postgres.ConnString())
 	if err != nil {
 		log.Fatalf("%s: %v", op, err)
 	}
+	poolConfig.MaxConns = int32(cfg.Postgres.MaxConns)
+	poolConfig.MinConns = int32(cfg.Postgres.MinConns)
+	poolConfig.MaxConnLifetime = cfg.Postgres.ConnMaxLifetime
+	poolConfig.MaxConnIdleTime = cfg.Postgres.ConnMaxIdleTime
 
-	createPostTable, err := db.Prepare(`
-	CREATE TABLE IF NOT EXISTS post (
-		id UUID PRIMARY KEY,
-		text TEXT NOT NULL,
-		authorPost VARCHAR(50) NOT NULL,
-		commentable BOOLEAN NOT NULL);
-`)
-	if err != nil {
+	if err := migrate(poolConfig.ConnConfig, cfg.Postgres.StartupTimeout); err != nil {
 		log.Fatalf("%s: %v", op, err)
 	}
-	_, err = createPostTable.Exec()
+
+	poolConfig.AfterConnect = prepareStatements
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		log.Fatalf("%s: %v", op, err)
 	}
 
-	createCommentTable, err := db.Prepare(`
-	CREATE TABLE IF NOT EXISTS comment (
-		id UUID PRIMARY KEY,
-		comment VARCHAR(2000),
-		authorComment VARCHAR(50) NOT NULL,
-		post_id UUID NOT NULL,
-		parent_comment_id UUID,
-		FOREIGN KEY (post_id) REFERENCES post(id),
-		FOREIGN KEY (parent_comment_id) REFERENCES comment(id)
-	);`)
+	var replicaPools []*pgxpool.Pool
+	for _, dsn := range cfg.Postgres.ReplicaConnStrings() {
+		replicaConfig, err := pgxpool.ParseConfig(dsn)
+		if err != nil {
+			log.Fatalf("%s: replica: %v", op, err)
+		}
+		replicaConfig.MaxConns = poolConfig.MaxConns
+		replicaConfig.MinConns = poolConfig.MinConns
+		replicaConfig.MaxConnLifetime = poolConfig.MaxConnLifetime
+		replicaConfig.MaxConnIdleTime = poolConfig.MaxConnIdleTime
+		replicaConfig.AfterConnect = prepareStatements
+		replicaPool, err := pgxpool.NewWithConfig(context.Background(), replicaConfig)
+		if err != nil {
+			log.Fatalf("%s: replica: %v", op, err)
+		}
+		replicaPools = append(replicaPools, replicaPool)
+	}
+
+	return &PostgresStorage{
+		Pool:        pool,
+		replicas:    newReplicaSet(replicaPools, cfg.Postgres.ReplicaHealthInterval),
+		readRetries: cfg.Postgres.ReadRetries,
+	}
+}
+
+// reader picks the pool for a read in ctx.
+func (s *PostgresStorage) reader(ctx context.Context) querier {
+	if s.replicas == nil || wroteInSession(ctx) {
+		return s.Pool
+	}
+	if replica := s.replicas.pick(); replica != nil {
+		return replica
+	}
+	return s.Pool
+}
+
+// migrate waits for the database to accept connections and brings the schema
+// up to date before the pool starts preparing statements against it.
+func migrate(connConfig *pgx.ConnConfig, startupTimeout time.Duration) error {
+	ctx := context.Background()
+	var conn *pgx.Conn
+	err := waitForDatabase(startupTimeout, func(ctx context.Context) error {
+		var err error
+		conn, err = pgx.ConnectConfig(ctx, connConfig)
+		return err
+	})
 	if err != nil {
-		log.Fatalf("%s: %v", op, err)
+		return fmt.Errorf("database is unavailable: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	for _, migration := range migrations {
+		if _, err := conn.Exec(ctx, migration); err != nil {
+			return err
+		}
 	}
-	_, err = createCommentTable.Exec()
+	_, err = conn.Exec(ctx, `
+	INSERT INTO schema_version (version)
+	SELECT $1 WHERE NOT EXISTS (SELECT 1 FROM schema_version);`, schemaVersion)
 	if err != nil {
-		log.Fatalf("%s: %v", op, err)
+		return err
 	}
+	_, err = conn.Exec(ctx, `UPDATE schema_version SET version = $1 WHERE version < $1;`, schemaVersion)
+	return err
+}
 
-	return &PostgresStorage{DB: db}
+func prepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, sql := range statements {
+		if _, err := conn.Prepare(ctx, name, sql); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
 }
 
 func (s *PostgresStorage) ClosePostgres() error {
-	return s.DB.Close()
+	if s.replicas != nil {
+		s.replicas.close()
+	}
+	s.Pool.Close()
+	return nil
 }
 
-func (s *PostgresStorage) GetAllPosts(ctx context.Context, limit, offset *int) ([]*models.Post, error) {
-	query := "SELECT id, text, authorPost, commentable FROM post"
-	var rows *sql.Rows
-	var err error
-
-	if limit != nil && offset != nil {
-		query += " LIMIT $1 OFFSET $2"
-		rows, err = s.DB.QueryContext(ctx, query, *limit, *offset)
-	} else {
-		rows, err = s.DB.QueryContext(ctx, query)
+func (s *PostgresStorage) Ping(ctx context.Context) error {
+	if err := s.Pool.Ping(ctx); err != nil {
+		return err
 	}
-
-	if err != nil {
-		return nil, err
+	var version int
+	err := s.Pool.QueryRow(ctx, stmtSchemaVersion).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errors.New("schema version not found")
+	} else if err != nil {
+		return err
 	}
-	defer rows.Close()
+	if version != schemaVersion {
+		return fmt.Errorf("schema version mismatch: have %d, want %d", version, schemaVersion)
+	}
+	return nil
+}
 
+func (s *PostgresStorage) GetAllPosts(ctx context.Context, limit, offset *int) ([]*models.Post, error) {
 	var posts []*models.Post
-	for rows.Next() {
-		var post models.Post
-		if err := rows.Scan(&post.ID, &post.TextPost, &post.AuthorPost, &post.Commentable); err != nil {
-			return nil, err
+	err := retryRead(ctx, s.readRetries, func() error {
+		rows, err := s.reader(ctx).Query(ctx, stmtAllPosts, limit, offset)
+		if err != nil {
+			return err
 		}
-		posts = append(posts, &post)
+		posts, err = pgx.CollectRows(rows, rowToPost)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 	return posts, nil
 }
 
 func (s *PostgresStorage) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
-	var post models.Post
-	err := s.DB.QueryRowContext(ctx, "SELECT id, text, authorPost, commentable FROM post WHERE id=$1", postID).Scan(&post.ID, &post.TextPost, &post.AuthorPost, &post.Commentable)
+	var post *models.Post
+	err := retryRead(ctx, s.readRetries, func() error {
+		var err error
+		post, err = scanPost(s.reader(ctx).QueryRow(ctx, stmtPostByID, postID))
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &post, nil
+	return post, nil
+}
+
+func (s *PostgresStorage) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*models.Post, error) {
+	if ids = validIDs(ids); len(ids) == 0 {
+		return map[string]*models.Post{}, nil
+	}
+	var posts []*models.Post
+	err := retryRead(ctx, s.readRetries, func() error {
+		rows, err := s.reader(ctx).Query(ctx, stmtPostsByIDs, ids)
+		if err != nil {
+			return err
+		}
+		posts, err = pgx.CollectRows(rows, rowToPost)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Post, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+	return byID, nil
+}
+
+// validIDs drops the ids that are not UUIDs. No row can have one, so they
+// are not found rather than failing the whole query with a type error.
+func validIDs(ids []string) []string {
+	valid := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err == nil {
+			valid = append(valid, id)
+		}
+	}
+	return valid
 }
 
-func (s *PostgresStorage) CreatePost(ctx context.Context, id, textPost string, commentable bool, authorPost string) (*models.Post, error) {
-	_, err := s.DB.ExecContext(ctx, "INSERT INTO post (id, text, authorPost, commentable) VALUES ($1, $2, $3, $4)", id, textPost, authorPost, commentable)
+func (s *PostgresStorage) CreatePost(ctx context.Context, id, textPost string, commentable bool, authorPost string, moderation models.Moderation) (*models.Post, error) {
+	if err := s.CheckSanction(ctx, authorPost, false); err != nil {
+		return nil, err
+	}
+	post, err := scanPost(s.Pool.QueryRow(ctx, stmtInsertPost, id, textPost, authorPost, commentable, moderation))
 	if err != nil {
 		return nil, err
 	}
-	return &models.Post{ID: id, TextPost: textPost, Commentable: commentable, AuthorPost: authorPost}, nil
+	markWrite(ctx)
+	return post, nil
 }
 
 func (s *PostgresStorage) GetAllComments(ctx context.Context, limit, offset *int) ([]*models.CommentResponse, error) {
-	query := "SELECT id, comment, authorComment, post_id, parent_comment_id FROM comment"
-	var params []interface{}
+	return s.queryComments(ctx, stmtAllComments, limit, offset)
+}
 
-	if limit != nil && offset != nil {
-		query += " LIMIT $1 OFFSET $2"
-		params = append(params, *limit, *offset)
-	} else if limit != nil {
-		query += " LIMIT $1"
-		params = append(params, *limit)
-	} else if offset != nil {
-		query += " OFFSET $1"
-		params = append(params, *offset)
+func (s *PostgresStorage) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*models.CommentResponse, error) {
+	return s.queryComments(ctx, stmtCommentsByPost, postID, limit, offset)
+}
+
+// GetCommentsByPostIDs batches one query per post, like GetCommentsByParentIDs.
+func (s *PostgresStorage) GetCommentsByPostIDs(ctx context.Context, postIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error) {
+	return s.batchComments(ctx, stmtCommentsByPost, postIDs, limit, offset)
+}
+
+func (s *PostgresStorage) GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*models.CommentResponse, error) {
+	return s.queryComments(ctx, stmtCommentsByParent, parentID, limit, offset)
+}
+
+// GetCommentsByParentIDs sends one query per parent in a single batch, so
+// loading replies for a page of comments costs one round trip.
+func (s *PostgresStorage) GetCommentsByParentIDs(ctx context.Context, parentIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error) {
+	return s.batchComments(ctx, stmtCommentsByParent, parentIDs, limit, offset)
+}
+
+// batchComments runs a comment list statement once per key in a single
+// batch and returns the lists keyed by the statement's first argument.
+func (s *PostgresStorage) batchComments(ctx context.Context, stmt string, keys []string, limit, offset *int) (map[string][]*models.CommentResponse, error) {
+	byKey := make(map[string][]*models.CommentResponse, len(keys))
+	if len(keys) == 0 {
+		return byKey, nil
 	}
 
-	rows, err := s.DB.QueryContext(ctx, query, params...)
+	err := retryRead(ctx, s.readRetries, func() error {
+		batch := &pgx.Batch{}
+		for _, key := range keys {
+			batch.Queue(stmt, key, limit, offset)
+		}
+		results := s.reader(ctx).SendBatch(ctx, batch)
+		defer results.Close()
+
+		for _, key := range keys {
+			rows, err := results.Query()
+			if err != nil {
+				return err
+			}
+			comments, err := pgx.CollectRows(rows, rowToComment)
+			if err != nil {
+				return err
+			}
+			byKey[key] = comments
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return byKey, nil
+}
 
+// GetCommentTree loads a whole thread in one recursive query. Rows come back
+// ordered by path, so every parent is seen before its replies.
+func (s *PostgresStorage) GetCommentTree(ctx context.Context, postID string, maxDepth, perLevelLimit int) ([]*models.CommentResponse, error) {
+	if maxDepth <= 0 {
+		maxDepth = math.MaxInt32
+	}
+	var limit *int
+	if perLevelLimit > 0 {
+		limit = &perLevelLimit
+	}
+
+	comments, err := s.queryComments(ctx, stmtCommentTree, postID, maxDepth, limit)
+	if err != nil {
+		return nil, err
+	}
+	return buildTree(comments), nil
+}
+
+func (s *PostgresStorage) queryComments(ctx context.Context, stmt string, args ...any) ([]*models.CommentResponse, error) {
 	var comments []*models.CommentResponse
-	for rows.Next() {
-		var comment models.CommentResponse
-		if err := rows.Scan(&comment.ID, &comment.TextComment, &comment.AuthorComment, &comment.PostID, &comment.ParentCommentID); err != nil {
-			return nil, err
+	err := retryRead(ctx, s.readRetries, func() error {
+		rows, err := s.reader(ctx).Query(ctx, stmt, args...)
+		if err != nil {
+			return err
 		}
-		comments = append(comments, &comment)
+		comments, err = pgx.CollectRows(rows, rowToComment)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 	return comments, nil
 }
 
-func (s *PostgresStorage) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*models.CommentResponse, error) {
-	query := "SELECT id, comment, authorComment, post_id, parent_comment_id FROM comment WHERE post_id=$1"
-	args := []interface{}{postID}
+func rowToPost(row pgx.CollectableRow) (*models.Post, error) {
+	return scanPost(row)
+}
 
-	if limit != nil {
-		query += " LIMIT $2"
-		args = append(args, *limit)
+func rowToComment(row pgx.CollectableRow) (*models.CommentResponse, error) {
+	return scanComment(row)
+}
+
+func scanPost(row pgx.Row) (*models.Post, error) {
+	var post models.Post
+	if err := row.Scan(&post.ID, &post.TextPost, &post.AuthorPost, &post.Commentable, &post.Moderation, &post.Status, &post.Edited, &post.Version); err != nil {
+		return nil, err
 	}
-	if offset != nil {
-		query += " OFFSET $3"
-		args = append(args, *offset)
+	return &post, nil
+}
+
+func scanComment(row pgx.Row) (*models.CommentResponse, error) {
+	var comment models.CommentResponse
+	err := row.Scan(&comment.ID, &comment.TextComment, &comment.AuthorComment, &comment.PostID, &comment.ParentCommentID, &comment.Status, &comment.Edited, &comment.Version)
+	if err != nil {
+		return nil, err
 	}
+	return &comment, nil
+}
 
-	rows, err := s.DB.QueryContext(ctx, query, args...)
+func (s *PostgresStorage) GetCommentByID(ctx context.Context, id string) (*models.CommentResponse, error) {
+	var comment *models.CommentResponse
+	err := retryRead(ctx, s.readRetries, func() error {
+		var err error
+		comment, err = scanComment(s.reader(ctx).QueryRow(ctx, stmtCommentByID, id))
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return comment, nil
+}
 
-	var comments []*models.CommentResponse
-	for rows.Next() {
-		var comment models.CommentResponse
-		err := rows.Scan(&comment.ID, &comment.TextComment, &comment.AuthorComment, &comment.PostID, &comment.ParentCommentID)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, &comment)
+func (s *PostgresStorage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.CommentResponse, error) {
+	if ids = validIDs(ids); len(ids) == 0 {
+		return map[string]*models.CommentResponse{}, nil
 	}
-	return comments, nil
+	comments, err := s.queryComments(ctx, stmtCommentsByIDs, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.CommentResponse, len(comments))
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+	}
+	return byID, nil
 }
 
-func (s *PostgresStorage) GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*models.CommentResponse, error) {
-	query := "SELECT id, comment, authorComment, post_id, parent_comment_id FROM comment WHERE parent_comment_id=$1"
+func (s *PostgresStorage) CreateComment(ctx context.Context, commentText, itemId, user string) (*models.CommentResponse, error) {
+	if err := s.CheckSanction(ctx, user, true); err != nil {
+		return nil, err
+	}
+	var parentCommentID *string
+	var postID string
+	var commentAble bool
+	var status models.Status
+	var moderation models.Moderation
 
-	if limit != nil && offset != nil {
-		query += " LIMIT $2 OFFSET $3"
-		rows, err := s.DB.QueryContext(ctx, query, parentID, *limit, *offset)
-		if err != nil {
+	err := s.Pool.QueryRow(ctx, stmtPostCommentable, itemId).Scan(&commentAble, &status, &moderation)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = s.Pool.QueryRow(ctx, stmtCommentPostID, itemId).Scan(&postID, &status, &moderation)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("item not found")
+		} else if err != nil {
 			return nil, err
 		}
-		defer rows.Close()
-		return scanerComments(rows)
+		parentCommentID = &itemId
+	} else if err != nil {
+		return nil, err
+	} else if !commentAble {
+		return nil, fmt.Errorf("%w: author turned off comments under this post", ErrNotCommentable)
+	} else {
+		postID = itemId
+	}
+	if status != models.StatusVisible {
+		return nil, ErrNotCommentable
+	}
+	var blocked bool
+	if err := s.Pool.QueryRow(ctx, stmtIsBlocked, postID, user).Scan(&blocked); err != nil {
+		return nil, err
+	} else if blocked {
+		return nil, ErrBlocked
 	}
 
-	rows, err := s.DB.QueryContext(ctx, query, parentID)
+	id := uuid.New().String()
+	status, queued := moderatedStatus(moderation)
+	comment, err := scanComment(s.Pool.QueryRow(ctx, stmtInsertComment, id, commentText, user, postID, parentCommentID, status, queued))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	return scanerComments(rows)
+	markWrite(ctx)
+	return comment, nil
 }
 
-func scanerComments(rows *sql.Rows) ([]*models.CommentResponse, error) {
+func (s *PostgresStorage) GetModerationQueue(ctx context.Context, first int, after string) (*models.ModerationQueue, error) {
+	afterSeq, err := parseQueueCursor(after)
+	if err != nil {
+		return nil, err
+	}
+	// The queue is read from the primary: moderators act on it right away.
+	rows, err := s.Pool.Query(ctx, stmtModerationQueue, afterSeq, first+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 	var comments []*models.CommentResponse
+	var seqs []uint64
 	for rows.Next() {
 		var comment models.CommentResponse
-		err := rows.Scan(&comment.ID, &comment.TextComment, &comment.AuthorComment, &comment.PostID, &comment.ParentCommentID)
+		var seq uint64
+		err := rows.Scan(&comment.ID, &comment.TextComment, &comment.AuthorComment, &comment.PostID, &comment.ParentCommentID, &comment.Status, &comment.Edited, &comment.Version, &seq)
 		if err != nil {
 			return nil, err
 		}
 		comments = append(comments, &comment)
+		seqs = append(seqs, seq)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	return comments, nil
+	return queuePage(comments, seqs, first), nil
 }
 
-func (s *PostgresStorage) GetCommentByID(ctx context.Context, id string) (*models.CommentResponse, error) {
-	var comment models.CommentResponse
-	err := s.DB.QueryRowContext(ctx, "SELECT id, comment, authorComment, post_id, parent_comment_id FROM comment WHERE id=$1", id).Scan(&comment.ID, &comment.TextComment, &comment.AuthorComment, &comment.PostID, &comment.ParentCommentID)
+func (s *PostgresStorage) UpdatePost(ctx context.Context, id, textPost string, expectedVersion int) (*models.Post, error) {
+	return s.updatePost(ctx, stmtUpdatePost, id, textPost, expectedVersion)
+}
+
+func (s *PostgresStorage) SetPostStatus(ctx context.Context, id string, status models.Status) (*models.Post, error) {
+	return s.updatePost(ctx, stmtSetPostStatus, id, status)
+}
+
+func (s *PostgresStorage) updatePost(ctx context.Context, stmt string, args ...any) (*models.Post, error) {
+	post, err := scanPost(s.Pool.QueryRow(ctx, stmt, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, s.notUpdated(ctx, stmtPostVersion, args[0], "post not found")
+	} else if err != nil {
+		return nil, err
+	}
+	markWrite(ctx)
+	return post, nil
+}
+
+func (s *PostgresStorage) UpdateComment(ctx context.Context, id, textComment string, expectedVersion int) (*models.CommentResponse, error) {
+	return s.updateComment(ctx, stmtUpdateComment, id, textComment, expectedVersion)
+}
+
+func (s *PostgresStorage) SetCommentStatus(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	return s.updateComment(ctx, stmtSetCommentStatus, id, status)
+}
+
+// notUpdated explains an update that matched no row: the item either does
+// not exist or is no longer at the expected version.
+func (s *PostgresStorage) notUpdated(ctx context.Context, versionStmt string, id any, notFound string) error {
+	var version int
+	err := s.Pool.QueryRow(ctx, versionStmt, id).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errors.New(notFound)
+	} else if err != nil {
+		return err
+	}
+	return &ConflictError{Current: version}
+}
+
+func (s *PostgresStorage) QueueComment(ctx context.Context, id string) error {
+	tag, err := s.Pool.Exec(ctx, stmtQueueComment, id)
 	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("comment not found")
+	}
+	markWrite(ctx)
+	return nil
+}
+
+func (s *PostgresStorage) ReviewComment(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error) {
+	comment, err := scanComment(s.Pool.QueryRow(ctx, stmtReviewComment, id, status))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotQueued
+	} else if err != nil {
 		return nil, err
 	}
-	return &comment, nil
+	markWrite(ctx)
+	return comment, nil
 }
 
-func (s *PostgresStorage) CreateComment(ctx context.Context, commentText, itemId, user string) (*models.CommentResponse, error) {
-	var isReply bool
-	var parentCommentID *string
-	var postID string
-	var commentAble bool
+func (s *PostgresStorage) updateComment(ctx context.Context, stmt string, args ...any) (*models.CommentResponse, error) {
+	comment, err := scanComment(s.Pool.QueryRow(ctx, stmt, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, s.notUpdated(ctx, stmtCommentVersion, args[0], "comment not found")
+	} else if err != nil {
+		return nil, err
+	}
+	markWrite(ctx)
+	return comment, nil
+}
 
-	err := s.DB.QueryRowContext(ctx, "SELECT commentable FROM post WHERE id=$1", itemId).Scan(&commentAble)
-	if errors.Is(err, sql.ErrNoRows) {
-		err = s.DB.QueryRowContext(ctx, "SELECT post_id FROM comment WHERE id=$1", itemId).Scan(&postID)
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("item not found")
-		} else if err != nil {
-			return nil, err
-		} else {
-			parentCommentID = &itemId
-			isReply = true
+func (s *PostgresStorage) CreateReport(ctx context.Context, itemID, reporter string, reason models.ReportReason, note *string) (*models.Report, int, error) {
+	report := &models.Report{ID: uuid.New().String(), ItemID: itemID, Reporter: reporter, Reason: reason, Note: note}
+	err := s.Pool.QueryRow(ctx, stmtItemType, itemID).Scan(&report.ItemType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, 0, errors.New("item not found")
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	err = s.Pool.QueryRow(ctx, stmtInsertReport, report.ID, itemID, report.ItemType, reporter, reason, note).Scan(&report.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, 0, ErrAlreadyReported
+	} else if err != nil {
+		return nil, 0, err
+	}
+	markWrite(ctx)
+
+	var count int
+	if err := s.Pool.QueryRow(ctx, stmtReportCount, itemID).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+	return report, count, nil
+}
+
+func (s *PostgresStorage) GetReports(ctx context.Context, limit, offset *int) ([]*models.ReportGroup, error) {
+	var groups []*models.ReportGroup
+	err := retryRead(ctx, s.readRetries, func() error {
+		rows, err := s.reader(ctx).Query(ctx, stmtReportGroups, limit, offset)
+		if err != nil {
+			return err
+		}
+		itemIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
 		}
+
+		rows, err = s.reader(ctx).Query(ctx, stmtReportsByItems, itemIDs)
+		if err != nil {
+			return err
+		}
+		reports, err := pgx.CollectRows(rows, rowToReport)
+		if err != nil {
+			return err
+		}
+
+		byItem := make(map[string]*models.ReportGroup, len(itemIDs))
+		groups = make([]*models.ReportGroup, len(itemIDs))
+		for i, id := range itemIDs {
+			groups[i] = &models.ReportGroup{ItemID: id}
+			byItem[id] = groups[i]
+		}
+		for _, r := range reports {
+			group := byItem[r.ItemID]
+			group.ItemType = r.ItemType
+			group.Count++
+			group.Reports = append(group.Reports, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func rowToReport(row pgx.CollectableRow) (*models.Report, error) {
+	var r models.Report
+	err := row.Scan(&r.ID, &r.ItemID, &r.ItemType, &r.Reporter, &r.Reason, &r.Note, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CheckSanction reads from the primary so a fresh ban applies at once.
+func (s *PostgresStorage) CheckSanction(ctx context.Context, userID string, comment bool) error {
+	var sanction models.Sanction
+	err := s.Pool.QueryRow(ctx, stmtSanction, userID).Scan(&sanction.Kind, &sanction.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
 	} else if err != nil {
+		return err
+	}
+	return sanctionError(&sanction, comment, time.Now())
+}
+
+func (s *PostgresStorage) BanUser(ctx context.Context, sanction *models.Sanction) (*models.Sanction, error) {
+	_, err := s.Pool.Exec(ctx, stmtUpsertSanction, sanction.UserID, sanction.Kind, sanction.Reason, sanction.CreatedBy, sanction.CreatedAt, sanction.ExpiresAt)
+	if err != nil {
 		return nil, err
-	} else if !commentAble {
-		return nil, errors.New("author turned off comments under this post")
-	} else {
-		postID = itemId
-		isReply = false
 	}
-	var query string
-	id := uuid.New().String()
-	if isReply {
-		query = "INSERT INTO comment (id, comment, authorComment, post_id, parent_comment_id) VALUES ($1, $2, $3, $4, $5)"
-		_, err := s.DB.ExecContext(ctx, query, id, commentText, user, postID, itemId)
+	markWrite(ctx)
+	return sanction, nil
+}
+
+func (s *PostgresStorage) UnbanUser(ctx context.Context, userID string) (bool, error) {
+	tag, err := s.Pool.Exec(ctx, stmtDeleteSanction, userID)
+	if err != nil {
+		return false, err
+	}
+	markWrite(ctx)
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *PostgresStorage) SweepSanctions(ctx context.Context, now time.Time) (int, error) {
+	tag, err := s.Pool.Exec(ctx, stmtSweepSanctions, now)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (s *PostgresStorage) BlockUser(ctx context.Context, postID, userID string) error {
+	if _, err := s.Pool.Exec(ctx, stmtInsertBlock, postID, userID); err != nil {
+		return err
+	}
+	markWrite(ctx)
+	return nil
+}
+
+func (s *PostgresStorage) UnblockUser(ctx context.Context, postID, userID string) (bool, error) {
+	tag, err := s.Pool.Exec(ctx, stmtDeleteBlock, postID, userID)
+	if err != nil {
+		return false, err
+	}
+	markWrite(ctx)
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *PostgresStorage) GetRevisions(ctx context.Context, itemID string) ([]*models.Revision, error) {
+	revisions := []*models.Revision{}
+	err := retryRead(ctx, s.readRetries, func() error {
+		rows, err := s.reader(ctx).Query(ctx, stmtRevisions, itemID)
 		if err != nil {
+			return err
+		}
+		revisions, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Revision, error) {
+			var r models.Revision
+			err := row.Scan(&r.Number, &r.Text, &r.CreatedAt)
+			return &r, err
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// ClaimIdempotencyKey takes over a free or expired key in one statement. A
+// key that expires or is released between the claim and the lookup of its
+// holder is claimed again.
+func (s *PostgresStorage) ClaimIdempotencyKey(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		now := time.Now()
+		var key string
+		err := s.Pool.QueryRow(ctx, stmtClaimIdemKey, record.Key, record.Fingerprint, record.ExpiresAt, now).Scan(&key)
+		if err == nil {
+			markWrite(ctx)
+			return nil, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
 			return nil, err
 		}
-		return &models.CommentResponse{ID: id, TextComment: commentText, AuthorComment: user, PostID: postID, ParentCommentID: parentCommentID}, nil
-	} else {
-		query = "INSERT INTO comment (id, comment, authorComment, post_id, parent_comment_id) VALUES ($1, $2, $3, $4, NULL)"
-		_, err := s.DB.ExecContext(ctx, query, id, commentText, user, postID)
+		var held IdempotencyRecord
+		err = s.Pool.QueryRow(ctx, stmtIdemKey, record.Key, now).Scan(&held.Key, &held.Fingerprint, &held.Result, &held.ExpiresAt)
+		if err == nil {
+			return &held, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+	}
+	return nil, ErrIdempotencyInProgress
+}
+
+func (s *PostgresStorage) CompleteIdempotencyKey(ctx context.Context, key string, result []byte, expiresAt time.Time) error {
+	_, err := s.Pool.Exec(ctx, stmtCompleteIdemKey, key, result, expiresAt)
+	return err
+}
+
+func (s *PostgresStorage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := s.Pool.Exec(ctx, stmtReleaseIdemKey, key)
+	return err
+}
+
+func (s *PostgresStorage) SweepIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	tag, err := s.Pool.Exec(ctx, stmtSweepIdemKeys, now)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (s *PostgresStorage) AppendAudit(ctx context.Context, entry *models.AuditEntry) error {
+	_, err := s.Pool.Exec(ctx, stmtInsertAudit, entry.ID, entry.At, entry.Actor, entry.ActorRole, entry.Action,
+		entry.TargetType, entry.TargetID, entry.RequestID, entry.Before, entry.After, entry.Diff, entry.Error)
+	return err
+}
+
+func (s *PostgresStorage) GetAuditLog(ctx context.Context, filter *models.AuditFilter, first int, after string) (*models.AuditLogPage, error) {
+	afterSeq, err := parseQueueCursor(after)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = &models.AuditFilter{}
+	}
+	rows, err := s.reader(ctx).Query(ctx, stmtAuditLog, afterSeq, filter.Actor, filter.Action, filter.TargetID, filter.Since, filter.Until, first+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []*models.AuditEntry
+	var seqs []uint64
+	for rows.Next() {
+		var e models.AuditEntry
+		var seq uint64
+		err := rows.Scan(&e.ID, &e.At, &e.Actor, &e.ActorRole, &e.Action, &e.TargetType, &e.TargetID, &e.RequestID,
+			&e.Before, &e.After, &e.Diff, &e.Error, &seq)
 		if err != nil {
 			return nil, err
 		}
-		return &models.CommentResponse{ID: id, TextComment: commentText, AuthorComment: user, PostID: postID}, nil
+		entries = append(entries, &e)
+		seqs = append(seqs, seq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
+	return auditPage(entries, seqs, first), nil
+}
+
+// CopyPosts bulk-loads posts with COPY. It bypasses the per-row checks of
+// CreatePost and is meant for imports and seeding.
+func (s *PostgresStorage) CopyPosts(ctx context.Context, posts []*models.Post) (int64, error) {
+	return s.Pool.CopyFrom(ctx,
+		pgx.Identifier{"post"},
+		[]string{"id", "text", "authorpost", "commentable", "moderation"},
+		pgx.CopyFromSlice(len(posts), func(i int) ([]any, error) {
+			p := posts[i]
+			moderation := p.Moderation
+			if moderation == "" {
+				moderation = models.ModerationNone
+			}
+			return []any{p.ID, p.TextPost, p.AuthorPost, p.Commentable, moderation}, nil
+		}),
+	)
+}
+
+// CopyComments bulk-loads comments with COPY. Parents must precede their
+// replies in the slice or already exist in the table.
+func (s *PostgresStorage) CopyComments(ctx context.Context, comments []*models.CommentResponse) (int64, error) {
+	return s.Pool.CopyFrom(ctx,
+		pgx.Identifier{"comment"},
+		[]string{"id", "comment", "authorcomment", "post_id", "parent_comment_id"},
+		pgx.CopyFromSlice(len(comments), func(i int) ([]any, error) {
+			c := comments[i]
+			return []any{c.ID, c.TextComment, c.AuthorComment, c.PostID, c.ParentCommentID}, nil
+		}),
+	)
 }
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// querier is the part of a pool the read paths use, so they can run against
+// the primary or a replica alike.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+type replica struct {
+	pool    *pgxpool.Pool
+	name    string
+	ping    func(ctx context.Context) error
+	healthy atomic.Bool
+}
+
+// replicaSet routes reads round robin over the replicas that passed their
+// last health check.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	done     chan struct{}
+}
+
+func newReplicaSet(pools []*pgxpool.Pool, interval time.Duration) *replicaSet {
+	rs := &replicaSet{done: make(chan struct{})}
+	for _, pool := range pools {
+		r := &replica{pool: pool, name: pool.Config().ConnConfig.Host, ping: pool.Ping}
+		r.healthy.Store(true)
+		rs.replicas = append(rs.replicas, r)
+	}
+	if len(rs.replicas) > 0 {
+		go rs.healthCheck(interval)
+	}
+	return rs
+}
+
+// pick returns a healthy replica or nil when there is none.
+func (rs *replicaSet) pick() *pgxpool.Pool {
+	n := len(rs.replicas)
+	if n == 0 {
+		return nil
+	}
+	start := rs.next.Add(1)
+	for i := 0; i < n; i++ {
+		r := rs.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() {
+			return r.pool
+		}
+	}
+	return nil
+}
+
+func (rs *replicaSet) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rs.done:
+			return
+		case <-ticker.C:
+		}
+		rs.check(interval)
+	}
+}
+
+// check pings every replica once and takes the ones that fail out of
+// rotation until they answer again.
+func (rs *replicaSet) check(timeout time.Duration) {
+	for _, r := range rs.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := r.ping(ctx)
+		cancel()
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("postgres replica %s is back", r.name)
+			} else {
+				log.Printf("postgres replica %s is unhealthy: %v", r.name, err)
+			}
+		}
+	}
+}
+
+func (rs *replicaSet) close() {
+	if len(rs.replicas) > 0 {
+		close(rs.done)
+	}
+	for _, r := range rs.replicas {
+		r.pool.Close()
+	}
+}
+
+type sessionKey struct{}
+
+// session tracks whether the current request has written to the primary.
+type session struct {
+	wrote atomic.Bool
+}
+
+// WithSession starts a read-your-writes scope, typically one per HTTP
+// request: once a write happens in ctx, later reads in it go to the primary
+// instead of a replica that may not have caught up.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+func markWrite(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.wrote.Store(true)
+	}
+}
+
+func wroteInSession(ctx context.Context) bool {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	return ok && s.wrote.Load()
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPool returns a pool that never connects; routing only compares pools.
+func testPool(t *testing.T, name string) *pgxpool.Pool {
+	t.Helper()
+	pool, err := pgxpool.New(context.Background(), "postgres://test@"+name+":1/test")
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// testReplicaSet builds a replica set without the background health check.
+// Each replica's ping fails while down[i] is set.
+func testReplicaSet(t *testing.T, n int) (*replicaSet, []*pgxpool.Pool, []bool) {
+	t.Helper()
+	rs := &replicaSet{done: make(chan struct{})}
+	pools := make([]*pgxpool.Pool, n)
+	down := make([]bool, n)
+	for i := range pools {
+		pools[i] = testPool(t, "replica"+strconv.Itoa(i))
+		r := &replica{pool: pools[i], name: strconv.Itoa(i), ping: func(ctx context.Context) error {
+			if down[i] {
+				return errors.New("connection refused")
+			}
+			return nil
+		}}
+		r.healthy.Store(true)
+		rs.replicas = append(rs.replicas, r)
+	}
+	return rs, pools, down
+}
+
+func TestReplicaSet_RoundRobin(t *testing.T) {
+	rs, pools, _ := testReplicaSet(t, 3)
+
+	counts := map[*pgxpool.Pool]int{}
+	var order []*pgxpool.Pool
+	for i := 0; i < 6; i++ {
+		pool := rs.pick()
+		counts[pool]++
+		order = append(order, pool)
+	}
+	for _, pool := range pools {
+		assert.Equal(t, 2, counts[pool])
+	}
+	assert.Equal(t, order[:3], order[3:], "replicas take turns in a fixed order")
+	assert.NotEqual(t, order[0], order[1])
+}
+
+func TestReplicaSet_SkipsUnhealthy(t *testing.T) {
+	rs, pools, down := testReplicaSet(t, 3)
+
+	down[1] = true
+	rs.check(time.Second)
+	for i := 0; i < 6; i++ {
+		assert.NotSame(t, pools[1], rs.pick())
+	}
+
+	down[0], down[2] = true, true
+	rs.check(time.Second)
+	assert.Nil(t, rs.pick(), "no healthy replica")
+
+	down[1] = false
+	rs.check(time.Second)
+	assert.Same(t, pools[1], rs.pick(), "a replica that answers again is back in rotation")
+
+	assert.Nil(t, (&replicaSet{}).pick())
+}
+
+func TestPostgresStorage_ReadYourWrites(t *testing.T) {
+	rs, pools, down := testReplicaSet(t, 1)
+	s := &PostgresStorage{Pool: testPool(t, "primary"), replicas: rs}
+
+	assert.Same(t, pools[0], s.reader(context.Background()))
+
+	// Writes outside a session do not pin anything to the primary.
+	markWrite(context.Background())
+	assert.Same(t, pools[0], s.reader(context.Background()))
+
+	ctx := WithSession(context.Background())
+	assert.Same(t, pools[0], s.reader(ctx))
+	markWrite(ctx)
+	assert.Same(t, s.Pool, s.reader(ctx), "reads after a write in the session go to the primary")
+	assert.Same(t, pools[0], s.reader(WithSession(context.Background())), "other sessions still use the replica")
+
+	down[0] = true
+	rs.check(time.Second)
+	assert.Same(t, s.Pool, s.reader(context.Background()), "the primary serves reads when no replica is healthy")
+
+	assert.Same(t, s.Pool, (&PostgresStorage{Pool: s.Pool}).reader(context.Background()), "no replicas configured")
+}
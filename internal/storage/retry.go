@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// waitForDatabase calls connect with exponential backoff until it succeeds
+// or timeout elapses.
+func waitForDatabase(timeout time.Duration, connect func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := connect(ctx)
+		if err == nil {
+			return nil
+		}
+		log.Printf("postgres is not ready (attempt %d): %v", attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// retryRead runs an idempotent read, retrying it on transient errors.
+func retryRead(ctx context.Context, retries int, read func() error) error {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		err := read()
+		if err == nil || attempt >= retries || !isTransient(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func isTransient(err error) bool {
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code[:2] {
+		case "08", // connection exception
+			"40", // transaction rollback: serialization failure, deadlock
+			"53": // insufficient resources, e.g. too many connections
+			return true
+		}
+		switch pgErr.Code {
+		case "57P01", "57P02", "57P03": // admin/crash shutdown, cannot connect now
+			return true
+		}
+	}
+	return false
+}
+
+// IsInternal reports whether err is a database or network failure rather
+// than an outcome of the request, so its message should not reach clients.
+func IsInternal(err error) bool {
+	var pgErr *pgconn.PgError
+	return isTransient(err) || errors.As(err, &pgErr) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+)
+
+var (
+	ErrBanned  = errors.New("user is banned")
+	ErrBlocked = errors.New("user is blocked by the post author")
+)
+
+// BannedError is returned when a banned or muted user tries to write.
+// It matches ErrBanned with errors.Is.
+type BannedError struct {
+	Kind      models.SanctionKind
+	ExpiresAt *time.Time
+}
+
+func (e *BannedError) Error() string {
+	msg := ErrBanned.Error()
+	if e.Kind == models.SanctionKindMute {
+		msg = "user is muted"
+	}
+	if e.ExpiresAt != nil {
+		msg += " until " + e.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return msg
+}
+
+func (e *BannedError) Is(target error) bool { return target == ErrBanned }
+
+// sanctionError reports whether sanction stops its user from creating a
+// post (or a comment when comment is true) at now.
+func sanctionError(sanction *models.Sanction, comment bool, now time.Time) error {
+	if sanction == nil || (sanction.ExpiresAt != nil && !sanction.ExpiresAt.After(now)) {
+		return nil
+	}
+	if sanction.Kind == models.SanctionKindMute && !comment {
+		return nil
+	}
+	return &BannedError{Kind: sanction.Kind, ExpiresAt: sanction.ExpiresAt}
+}
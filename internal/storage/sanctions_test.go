@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSanctionsEnforced checks that a backend refuses writes by sanctioned
+// users itself, whichever layer calls it.
+func testSanctionsEnforced(t *testing.T, s Storage) {
+	ctx := context.Background()
+	author, banned, muted := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	post, err := s.CreatePost(ctx, uuid.NewString(), "post", true, author, models.ModerationNone)
+	require.NoError(t, err)
+
+	_, err = s.BanUser(ctx, &models.Sanction{UserID: banned, Kind: models.SanctionKindBan, CreatedAt: time.Now()})
+	require.NoError(t, err)
+	_, err = s.BanUser(ctx, &models.Sanction{UserID: muted, Kind: models.SanctionKindMute, CreatedAt: time.Now()})
+	require.NoError(t, err)
+
+	_, err = s.CreatePost(ctx, uuid.NewString(), "post", true, banned, models.ModerationNone)
+	assert.ErrorIs(t, err, ErrBanned)
+	_, err = s.CreateComment(ctx, "comment", post.ID, banned)
+	assert.ErrorIs(t, err, ErrBanned)
+
+	// A mute only stops comments.
+	_, err = s.CreatePost(ctx, uuid.NewString(), "post", true, muted, models.ModerationNone)
+	assert.NoError(t, err)
+	_, err = s.CreateComment(ctx, "comment", post.ID, muted)
+	assert.ErrorIs(t, err, ErrBanned)
+
+	_, err = s.UnbanUser(ctx, banned)
+	require.NoError(t, err)
+	_, err = s.CreateComment(ctx, "comment", post.ID, banned)
+	assert.NoError(t, err)
+}
+
+func TestInMemoryStorage_SanctionsEnforced(t *testing.T) {
+	testSanctionsEnforced(t, NewMemoryStorage())
+}
+
+func TestPostgresStorage_SanctionsEnforced(t *testing.T) {
+	testSanctionsEnforced(t, newTestPostgres(t))
+}
@@ -2,27 +2,112 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/NGerasimovvv/GraphQL/internal/config"
 	"github.com/NGerasimovvv/GraphQL/internal/models"
 )
 
+// ErrNotCommentable is returned when replying to a post or comment that was
+// hidden, deleted or has not been approved yet, or to a post whose author
+// turned off comments.
+var ErrNotCommentable = errors.New("item is not open for comments")
+
+var ErrAlreadyReported = errors.New("item already reported by this user")
+
+var ErrConflict = errors.New("item was changed concurrently")
+
+// ConflictError is returned by an update whose expected version is stale.
+// It matches ErrConflict with errors.Is.
+type ConflictError struct {
+	Current int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: current version is %d", ErrConflict, e.Current)
+}
+
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
 type Storage interface {
+	Ping(ctx context.Context) error
+
 	GetAllPosts(ctx context.Context, limit, offset *int) ([]*models.Post, error)
 	GetPostByID(ctx context.Context, postID string) (*models.Post, error)
-	CreatePost(ctx context.Context, id, textPost string, commentable bool, authorPost string) (*models.Post, error)
+	// GetPostsByIDs and GetCommentsByIDs load many items at once, keyed by
+	// ID. IDs that do not exist are left out of the map.
+	GetPostsByIDs(ctx context.Context, ids []string) (map[string]*models.Post, error)
+	CreatePost(ctx context.Context, id, textPost string, commentable bool, authorPost string, moderation models.Moderation) (*models.Post, error)
+	// UpdatePost and UpdateComment fail with a ConflictError unless the item
+	// is at expectedVersion. Every change increments an item's version.
+	UpdatePost(ctx context.Context, id, textPost string, expectedVersion int) (*models.Post, error)
+	SetPostStatus(ctx context.Context, id string, status models.Status) (*models.Post, error)
 
 	GetAllComments(ctx context.Context, limit, offset *int) ([]*models.CommentResponse, error)
 	GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*models.CommentResponse, error) // Обновлено
+	// GetCommentsByPostIDs returns what GetCommentsByPostID would for each
+	// post, keyed by post ID.
+	GetCommentsByPostIDs(ctx context.Context, postIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error)
 	GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*models.CommentResponse, error)
+	GetCommentsByParentIDs(ctx context.Context, parentIDs []string, limit, offset *int) (map[string][]*models.CommentResponse, error)
+	GetCommentTree(ctx context.Context, postID string, maxDepth, perLevelLimit int) ([]*models.CommentResponse, error)
 	GetCommentByID(ctx context.Context, id string) (*models.CommentResponse, error)
+	GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.CommentResponse, error)
 	CreateComment(ctx context.Context, textComment, itemId, user string) (*models.CommentResponse, error)
+	UpdateComment(ctx context.Context, id, textComment string, expectedVersion int) (*models.CommentResponse, error)
+	SetCommentStatus(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error)
+	// GetRevisions lists every version of a post or comment text, oldest first.
+	GetRevisions(ctx context.Context, itemID string) ([]*models.Revision, error)
+
+	// GetModerationQueue lists comments awaiting review, oldest first.
+	// Cursors are opaque; an empty after starts from the beginning.
+	GetModerationQueue(ctx context.Context, first int, after string) (*models.ModerationQueue, error)
+	// QueueComment puts a comment on the moderation queue without changing its status.
+	QueueComment(ctx context.Context, id string) error
+	// ReviewComment sets the status of a queued comment and takes it off the queue.
+	ReviewComment(ctx context.Context, id string, status models.Status) (*models.CommentResponse, error)
+
+	// CreateReport files a report against a post or comment and returns the
+	// number of distinct users who have reported the item so far.
+	CreateReport(ctx context.Context, itemID, reporter string, reason models.ReportReason, note *string) (*models.Report, int, error)
+	// GetReports groups reports by item, most reported items first.
+	GetReports(ctx context.Context, limit, offset *int) ([]*models.ReportGroup, error)
+
+	// CheckSanction returns a BannedError if userID may not create a post, or
+	// a comment when comment is true. CreatePost and CreateComment enforce
+	// it themselves, as they do post blocks.
+	CheckSanction(ctx context.Context, userID string, comment bool) error
+	// BanUser stores a sanction, replacing any earlier one for the same user.
+	BanUser(ctx context.Context, sanction *models.Sanction) (*models.Sanction, error)
+	UnbanUser(ctx context.Context, userID string) (bool, error)
+	// SweepSanctions deletes sanctions that expired before now.
+	SweepSanctions(ctx context.Context, now time.Time) (int, error)
+	BlockUser(ctx context.Context, postID, userID string) error
+	UnblockUser(ctx context.Context, postID, userID string) (bool, error)
+
+	// ClaimIdempotencyKey reserves record.Key until record.ExpiresAt. If the
+	// key is held by an unexpired record, that record is returned instead.
+	ClaimIdempotencyKey(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the result of a claimed key.
+	CompleteIdempotencyKey(ctx context.Context, key string, result []byte, expiresAt time.Time) error
+	// ReleaseIdempotencyKey frees a claimed key whose request failed.
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	// SweepIdempotencyKeys deletes keys that expired before now.
+	SweepIdempotencyKeys(ctx context.Context, now time.Time) (int, error)
+
+	// AppendAudit adds an entry to the audit log. Entries are never changed
+	// or removed once written.
+	AppendAudit(ctx context.Context, entry *models.AuditEntry) error
+	// GetAuditLog lists audit entries matching filter, newest first.
+	GetAuditLog(ctx context.Context, filter *models.AuditFilter, first int, after string) (*models.AuditLogPage, error)
 }
 
 func StorageType(cfg *config.Config) Storage {
 	storageType := cfg.Storage.StorageType
 	var storage Storage
-	if storageType == "memory" {
+	if storageType == config.StorageMemory {
 		storage = InitMemoryStorage()
 	} else {
 		storage = InitPostgresDatabase(cfg)
@@ -0,0 +1,22 @@
+package storage
+
+import "github.com/NGerasimovvv/GraphQL/internal/models"
+
+// buildTree links comments into threads and returns the top-level ones.
+// Parents must precede their replies in the input.
+func buildTree(comments []*models.CommentResponse) []*models.CommentResponse {
+	byID := make(map[string]*models.CommentResponse, len(comments))
+	roots := make([]*models.CommentResponse, 0)
+	for _, comment := range comments {
+		comment.Replies = []*models.CommentResponse{}
+		byID[comment.ID] = comment
+		if comment.ParentCommentID == nil {
+			roots = append(roots, comment)
+			continue
+		}
+		if parent, ok := byID[*comment.ParentCommentID]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		}
+	}
+	return roots
+}
@@ -0,0 +1,171 @@
+// Package textdiff computes line and word diffs between two texts.
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Chunk is a run of text that is kept, inserted or deleted.
+type Chunk struct {
+	Op   Op
+	Text string
+}
+
+// maxCells bounds the work of the longest common subsequence, the product of
+// the lengths of the changed middle of the texts. Past it the middle is
+// reported as one deletion and one insertion. Memory stays linear in the
+// length of the texts either way.
+const maxCells = 1 << 20
+
+// Lines diffs a and b line by line. Each line keeps its trailing newline.
+func Lines(a, b string) []Chunk {
+	return diff(splitLines(a), splitLines(b))
+}
+
+// Words diffs a and b word by word. Runs of whitespace are tokens of their
+// own, so the chunks concatenate back to the original texts.
+func Words(a, b string) []Chunk {
+	return diff(splitWords(a), splitWords(b))
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func splitWords(s string) []string {
+	var words []string
+	start, space := 0, false
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != space {
+			words = append(words, s[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+func diff(a, b []string) []Chunk {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var chunks []Chunk
+	add := func(op Op, text string) {
+		if n := len(chunks); n > 0 && chunks[n-1].Op == op {
+			chunks[n-1].Text += text
+			return
+		}
+		chunks = append(chunks, Chunk{Op: op, Text: text})
+	}
+	for _, t := range a[:prefix] {
+		add(Equal, t)
+	}
+	middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], add)
+	for _, t := range a[len(a)-suffix:] {
+		add(Equal, t)
+	}
+	return chunks
+}
+
+// middle emits the diff of a and b from their longest common subsequence,
+// deletions before insertions within each changed run.
+func middle(a, b []string, add func(Op, string)) {
+	var matches [][2]int
+	if len(a)*len(b) <= maxCells {
+		matches = lcs(a, b, 0, 0, nil)
+	}
+	i, j := 0, 0
+	for _, match := range append(matches, [2]int{len(a), len(b)}) {
+		for ; i < match[0]; i++ {
+			add(Delete, a[i])
+		}
+		for ; j < match[1]; j++ {
+			add(Insert, b[j])
+		}
+		if i < len(a) {
+			add(Equal, a[i])
+			i, j = i+1, j+1
+		}
+	}
+}
+
+// lcs appends the index pairs of a longest common subsequence of a and b,
+// offset by ai and bj, to matches. It splits a in half and b where the
+// halves' subsequences meet (Hirschberg), so it needs only linear space.
+func lcs(a, b []string, ai, bj int, matches [][2]int) [][2]int {
+	if len(a) == 0 || len(b) == 0 {
+		return matches
+	}
+	if len(a) == 1 {
+		for j, t := range b {
+			if t == a[0] {
+				return append(matches, [2]int{ai, bj + j})
+			}
+		}
+		return matches
+	}
+
+	mid := len(a) / 2
+	head := lcsLengths(a[:mid], b)
+	tail := lcsLengths(reversed(a[mid:]), reversed(b))
+	split, best := 0, -1
+	for j := range head {
+		if n := head[j] + tail[len(b)-j]; n > best {
+			split, best = j, n
+		}
+	}
+	matches = lcs(a[:mid], b[:split], ai, bj, matches)
+	return lcs(a[mid:], b[split:], ai+mid, bj+split, matches)
+}
+
+// lcsLengths returns, for each j, the length of the longest common
+// subsequence of a and b[:j].
+func lcsLengths(a, b []string) []int {
+	row := make([]int, len(b)+1)
+	for _, t := range a {
+		diag := 0
+		for j := 1; j <= len(b); j++ {
+			up := row[j]
+			if t == b[j-1] {
+				row[j] = diag + 1
+			} else if row[j-1] > up {
+				row[j] = row[j-1]
+			}
+			diag = up
+		}
+	}
+	return row
+}
+
+func reversed(s []string) []string {
+	r := make([]string, len(s))
+	for i, t := range s {
+		r[len(s)-1-i] = t
+	}
+	return r
+}
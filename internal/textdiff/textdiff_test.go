@@ -0,0 +1,121 @@
+package textdiff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLines(t *testing.T) {
+	chunks := Lines("one\ntwo\nthree\n", "one\n2\nthree\nfour")
+	assert.Equal(t, []Chunk{
+		{Equal, "one\n"},
+		{Delete, "two\n"},
+		{Insert, "2\n"},
+		{Equal, "three\n"},
+		{Insert, "four"},
+	}, chunks)
+}
+
+func TestWords(t *testing.T) {
+	chunks := Words("the quick  fox", "the slow  fox jumps")
+	assert.Equal(t, []Chunk{
+		{Equal, "the "},
+		{Delete, "quick"},
+		{Insert, "slow"},
+		{Equal, "  fox"},
+		{Insert, " jumps"},
+	}, chunks)
+}
+
+func TestWords_Unicode(t *testing.T) {
+	chunks := Words("привет мир", "привет, мир")
+	assert.Equal(t, []Chunk{
+		{Delete, "привет"},
+		{Insert, "привет,"},
+		{Equal, " мир"},
+	}, chunks)
+}
+
+func TestDiff_Reassembles(t *testing.T) {
+	a := "a b c d e f"
+	b := "x b d c f g"
+	var before, after strings.Builder
+	for _, c := range Words(a, b) {
+		if c.Op != Insert {
+			before.WriteString(c.Text)
+		}
+		if c.Op != Delete {
+			after.WriteString(c.Text)
+		}
+	}
+	assert.Equal(t, a, before.String())
+	assert.Equal(t, b, after.String())
+}
+
+func TestDiff_Empty(t *testing.T) {
+	assert.Nil(t, Lines("", ""))
+	assert.Equal(t, []Chunk{{Insert, "new"}}, Lines("", "new"))
+}
+
+func TestDiff_LongestCommonSubsequence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		tokens := make([]string, rng.Intn(12))
+		for i := range tokens {
+			tokens[i] = string(rune('a' + rng.Intn(3)))
+		}
+		return tokens
+	}
+	for n := 0; n < 500; n++ {
+		a, b := random(), random()
+		// The reference table of LCS lengths.
+		table := make([][]int, len(a)+1)
+		for i := range table {
+			table[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					table[i][j] = table[i+1][j+1] + 1
+				} else {
+					table[i][j] = max(table[i+1][j], table[i][j+1])
+				}
+			}
+		}
+
+		equal := 0
+		var before, after []string
+		for _, c := range diff(a, b) {
+			if c.Op == Equal {
+				equal += len(c.Text)
+			}
+			if c.Op != Insert {
+				before = append(before, c.Text)
+			}
+			if c.Op != Delete {
+				after = append(after, c.Text)
+			}
+		}
+		assert.Equal(t, table[0][0], equal, "%q %q", a, b)
+		assert.Equal(t, strings.Join(a, ""), strings.Join(before, ""))
+		assert.Equal(t, strings.Join(b, ""), strings.Join(after, ""))
+	}
+}
+
+func TestLines_Long(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 900; i++ {
+		line := strings.Repeat("x", i%7) + "\n"
+		a.WriteString(line)
+		if i == 450 {
+			b.WriteString("changed\n")
+		}
+		b.WriteString(line)
+	}
+	chunks := Lines(a.String(), b.String())
+	assert.Len(t, chunks, 3)
+	assert.Equal(t, Chunk{Insert, "changed\n"}, chunks[1])
+}
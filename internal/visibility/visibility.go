@@ -0,0 +1,92 @@
+// Package visibility decides what readers see of moderated posts and
+// comments, for every API that serves them. Moderators see every item as
+// stored. Everyone else gets hidden and deleted items as tombstones that keep
+// their place in the thread and their replies, while pending and rejected
+// items are left out.
+package visibility
+
+import (
+	"context"
+
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/models"
+)
+
+// Tombstone replaces the text and author of hidden and deleted items.
+const Tombstone = "[deleted]"
+
+// Posts filters and presents a list of posts for the caller.
+func Posts(ctx context.Context, posts []*models.Post) []*models.Post {
+	if auth.IsModerator(ctx) {
+		return posts
+	}
+	visible := posts[:0]
+	for _, post := range posts {
+		if Unlisted(post.Status) {
+			continue
+		}
+		visible = append(visible, Post(ctx, post))
+	}
+	return visible
+}
+
+// Post presents post for the caller, or returns nil if they may not see it.
+func Post(ctx context.Context, post *models.Post) *models.Post {
+	if auth.IsModerator(ctx) {
+		return post
+	}
+	if Unlisted(post.Status) {
+		return nil
+	}
+	switch post.Status {
+	case models.StatusHidden, models.StatusDeleted:
+		post.TextPost = Tombstone
+		post.AuthorPost = Tombstone
+	}
+	post.Comments = Comments(ctx, post.Comments)
+	return post
+}
+
+// Comments filters and presents a list of comments for the caller.
+func Comments(ctx context.Context, comments []*models.CommentResponse) []*models.CommentResponse {
+	if auth.IsModerator(ctx) || comments == nil {
+		return comments
+	}
+	visible := comments[:0]
+	for _, comment := range comments {
+		if c := Comment(ctx, comment); c != nil {
+			visible = append(visible, c)
+		}
+	}
+	return visible
+}
+
+// Comment presents comment for the caller, or returns nil if they may not
+// see it.
+func Comment(ctx context.Context, comment *models.CommentResponse) *models.CommentResponse {
+	if auth.IsModerator(ctx) {
+		return comment
+	}
+	if Unlisted(comment.Status) {
+		return nil
+	}
+	switch comment.Status {
+	case models.StatusHidden, models.StatusDeleted:
+		comment.TextComment = Tombstone
+		comment.AuthorComment = Tombstone
+	}
+	comment.Replies = Comments(ctx, comment.Replies)
+	return comment
+}
+
+// Unlisted reports whether readers other than moderators must not see an
+// item with status at all.
+func Unlisted(status models.Status) bool {
+	return status == models.StatusPending || status == models.StatusRejected
+}
+
+// TextHidden reports whether the caller sees a tombstone instead of the text
+// of an item with status, and so must not see its revisions either.
+func TextHidden(ctx context.Context, status models.Status) bool {
+	return status != models.StatusVisible && !auth.IsModerator(ctx)
+}
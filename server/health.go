@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/NGerasimovvv/GraphQL/internal/buildinfo"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const readinessTimeout = 2 * time.Second
+
+func healthzHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+func readyzHandler(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+		if err := storage.Ping(ctx); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+func versionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, buildinfo.Get())
+	}
+}
+
+// Probe endpoints are registered on the bare router, before newRouter adds
+// its middleware, so that only gin's logger and recovery apply to them.
+func registerProbes(r *gin.Engine, storage storage.Storage) {
+	r.GET("/healthz", healthzHandler())
+	r.GET("/readyz", readyzHandler(storage))
+	r.GET("/version", versionHandler())
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/NGerasimovvv/GraphQL/internal/buildinfo"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// downStorage fails the readiness check like an unreachable database.
+type downStorage struct {
+	storage.Storage
+}
+
+func (downStorage) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func probeRouter(store storage.Storage) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerProbes(r, store)
+	return r
+}
+
+func TestProbes(t *testing.T) {
+	ready := probeRouter(storage.NewMemoryStorage())
+	rec := serve(ready, http.MethodGet, "/healthz", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+	rec = serve(ready, http.MethodGet, "/readyz", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+
+	unready := probeRouter(downStorage{storage.NewMemoryStorage()})
+	rec = serve(unready, http.MethodGet, "/readyz", "", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"unavailable","error":"connection refused"}`, rec.Body.String())
+	// The process itself is still alive.
+	assert.Equal(t, http.StatusOK, serve(unready, http.MethodGet, "/healthz", "", nil).Code)
+
+	rec = serve(ready, http.MethodGet, "/version", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var info buildinfo.Info
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, buildinfo.Get(), info)
+}
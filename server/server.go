@@ -1,40 +1,135 @@
 package server
 
 import (
+	"context"
+
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/NGerasimovvv/GraphQL/graph"
+	"github.com/NGerasimovvv/GraphQL/internal/auth"
+	"github.com/NGerasimovvv/GraphQL/internal/cache"
+	"github.com/NGerasimovvv/GraphQL/internal/config"
 	"github.com/NGerasimovvv/GraphQL/internal/gateway"
+	"github.com/NGerasimovvv/GraphQL/internal/httpcache"
+	"github.com/NGerasimovvv/GraphQL/internal/idempotency"
+	"github.com/NGerasimovvv/GraphQL/internal/persisted"
+	"github.com/NGerasimovvv/GraphQL/internal/requestid"
+	"github.com/NGerasimovvv/GraphQL/internal/rest"
 	"github.com/NGerasimovvv/GraphQL/internal/storage"
 	"github.com/gin-gonic/gin"
 	"log"
 )
 
-func graphqlHandler(storage storage.Storage) gin.HandlerFunc {
-	postGateway := gateway.NewPostGateway(storage)
-	commentGateway := gateway.NewCommentGateway(storage)
+// newResolver sets up the gateways, which the GraphQL and REST APIs share.
+func newResolver(cfg *config.Config, store storage.Storage) *graph.Resolver {
+	filters, err := gateway.NewPipeline(cfg.Filter)
+	if err != nil {
+		log.Fatalf("content filters: %v", err)
+	}
+	return &graph.Resolver{
+		PostGateway:     gateway.NewPostGateway(store),
+		CommentGateway:  gateway.NewCommentGateway(store, filters...),
+		ReportGateway:   gateway.NewReportGateway(store, cfg.Moderation.ReportThreshold),
+		SanctionGateway: gateway.NewSanctionGateway(store),
+		AuditGateway:    gateway.NewAuditGateway(store),
+		Idempotency:     gateway.NewIdempotencyGateway(store, cfg.Idempotency.Window),
+	}
+}
 
-	h := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{
-		Resolvers: &graph.Resolver{
-			PostGateway:    postGateway,
-			CommentGateway: commentGateway,
-		},
-	}))
+func graphqlHandler(cfg *config.Config, resolver *graph.Resolver) gin.HandlerFunc {
+	h := graph.NewHandler(resolver, graph.HandlerOptions{
+		Introspection: cfg.Server.Dev(),
+		VerboseErrors: cfg.Server.Dev(),
+	})
+	if err := usePersistedQueries(h, cfg.PersistedQueries); err != nil {
+		log.Fatalf("persisted queries: %v", err)
+	}
 	return func(c *gin.Context) {
 		h.ServeHTTP(c.Writer, c.Request)
 	}
 }
 
-func InitServer(storage storage.Storage) {
-	gin.SetMode(gin.DebugMode)
-	r := gin.Default()
+// withSession starts a read-your-writes storage session for the request.
+func withSession(c *gin.Context) {
+	c.Request = c.Request.WithContext(storage.WithSession(c.Request.Context()))
+	c.Next()
+}
+
+// usePersistedQueries enables automatic persisted queries backed by an LRU,
+// with the manifest's operations pinned, or, in strict mode, restricts the
+// handler to the manifest's operations.
+func usePersistedQueries(h *handler.Server, cfg *config.PersistedQueriesConfig) error {
+	if cfg.Strict {
+		manifest, err := persisted.LoadManifest(cfg.Manifest)
+		if err != nil {
+			return err
+		}
+		h.Use(persisted.NewAllowlist(manifest))
+		log.Printf("persisted queries: executing only the %d operations of %s", len(manifest.Operations), cfg.Manifest)
+		return nil
+	}
+	if cfg.CacheSize == 0 {
+		return nil
+	}
+	queries := persisted.NewCache(cache.NewLRU(cfg.CacheSize, cfg.TTL), cfg.TTL)
+	if cfg.Manifest != "" {
+		manifest, err := persisted.LoadManifest(cfg.Manifest)
+		if err != nil {
+			return err
+		}
+		queries.Pin(manifest)
+	}
+	h.Use(extension.AutomaticPersistedQuery{Cache: queries})
+	return nil
+}
 
-	r.POST("/graphql", graphqlHandler(storage))
-	r.GET("/", playgroundHandler())
-	log.Println("connect to http://localhost:8000/ for GraphQL playground")
+func InitServer(cfg *config.Config, storage storage.Storage) {
+	r := newRouter(cfg, storage)
+	go gateway.SweepSanctions(context.Background(), storage, cfg.Moderation.SanctionSweepInterval)
+	go gateway.SweepIdempotencyKeys(context.Background(), storage, cfg.Idempotency.SweepInterval)
+	if cfg.Server.Dev() {
+		log.Println("connect to http://localhost:8000/ for GraphQL playground")
+	}
 	log.Fatal(r.Run(":8000"))
 }
 
+// newRouter sets up the routes for the configured profile: prod runs gin in
+// release mode, sends secure headers and serves no playground.
+func newRouter(cfg *config.Config, storage storage.Storage) *gin.Engine {
+	if cfg.Server.Dev() {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := gin.Default()
+	registerProbes(r, storage)
+	r.Use(requestid.Middleware())
+	if !cfg.Server.Dev() {
+		r.Use(secureHeaders())
+	}
+	if len(cfg.Server.AllowedOrigins) > 0 {
+		r.Use(corsMiddleware(cfg.Server.AllowedOrigins))
+	}
+
+	resolver := newResolver(cfg, storage)
+	serveGraphQL := graphqlHandler(cfg, resolver)
+	r.POST("/graphql", auth.Middleware(cfg.Server.GatewaySecret), idempotency.Middleware(), withSession, serveGraphQL)
+	// GET serves queries only, so responses can be cached by browsers and CDNs.
+	r.GET("/graphql", auth.Middleware(cfg.Server.GatewaySecret), httpcache.Middleware(), withSession, serveGraphQL)
+	api := &rest.API{
+		Posts:         resolver.PostGateway,
+		Comments:      resolver.CommentGateway,
+		Idempotency:   resolver.Idempotency,
+		VerboseErrors: cfg.Server.Dev(),
+	}
+	api.Register(r.Group("/api/v1", auth.Middleware(cfg.Server.GatewaySecret), idempotency.Middleware(), withSession))
+	if cfg.Server.Dev() {
+		r.GET("/", playgroundHandler())
+	}
+	return r
+}
+
 func playgroundHandler() gin.HandlerFunc {
 	h := playground.Handler("GraphQL", "/graphql")
 	return func(c *gin.Context) {
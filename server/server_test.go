@@ -0,0 +1,177 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NGerasimovvv/GraphQL/internal/config"
+	"github.com/NGerasimovvv/GraphQL/internal/persisted"
+	"github.com/NGerasimovvv/GraphQL/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRouter(t *testing.T, args ...string) *gin.Engine {
+	t.Helper()
+	cfg, err := config.LoadConfig(append([]string{"-storage", "memory"}, args...))
+	require.NoError(t, err)
+	return newRouter(cfg, storage.NewMemoryStorage())
+}
+
+func serve(r http.Handler, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+const introspection = `{"query":"{ __schema { queryType { name } } }"}`
+
+func TestProfiles(t *testing.T) {
+	dev := testRouter(t, "-profile", "dev")
+	assert.Equal(t, http.StatusOK, serve(dev, http.MethodGet, "/", "", nil).Code)
+	rec := serve(dev, http.MethodPost, "/graphql", introspection, nil)
+	assert.Contains(t, rec.Body.String(), `"queryType":{"name":"Query"}`)
+	assert.Empty(t, rec.Header().Get("X-Frame-Options"))
+
+	prod := testRouter(t, "-profile", "prod", "-gateway-secret", "s3cret")
+	assert.Equal(t, http.StatusNotFound, serve(prod, http.MethodGet, "/", "", nil).Code)
+	rec = serve(prod, http.MethodPost, "/graphql", introspection, nil)
+	assert.Contains(t, rec.Body.String(), "introspection disabled")
+	assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.NotEmpty(t, rec.Header().Get("Strict-Transport-Security"))
+
+	rec = serve(prod, http.MethodPost, "/graphql", `{"query":"{ posts { id } }"}`, nil)
+	assert.JSONEq(t, `{"data":{"posts":[]}}`, rec.Body.String())
+
+	// Probes skip the router's middleware.
+	rec = serve(prod, http.MethodGet, "/healthz", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-Frame-Options"))
+}
+
+func TestProfiles_FederationInProd(t *testing.T) {
+	prod := testRouter(t, "-profile", "prod", "-gateway-secret", "s3cret")
+	router := map[string]string{"X-Gateway-Secret": "s3cret"}
+
+	rec := serve(prod, http.MethodPost, "/graphql", `{"query":"{ _service { sdl } }"}`, router)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `"errors"`)
+	assert.Contains(t, rec.Body.String(), "type Post")
+
+	// Anyone else gets no schema.
+	rec = serve(prod, http.MethodPost, "/graphql", `{"query":"{ _service { sdl } }"}`, nil)
+	assert.Contains(t, rec.Body.String(), "introspection disabled")
+	assert.NotContains(t, rec.Body.String(), "type Post")
+
+	// Asking for the schema next to _service is still refused.
+	rec = serve(prod, http.MethodPost, "/graphql", `{"query":"{ _service { sdl } __schema { queryType { name } } }"}`, router)
+	assert.Contains(t, rec.Body.String(), "introspection disabled")
+}
+
+func TestGatewaySecret(t *testing.T) {
+	r := testRouter(t, "-gateway-secret", "s3cret")
+	const queue = `{"query":"{ moderationQueue { hasNextPage } }"}`
+	moderator := map[string]string{"X-User-ID": "mod", "X-User-Role": "moderator"}
+
+	rec := serve(r, http.MethodPost, "/graphql", queue, moderator)
+	assert.Contains(t, rec.Body.String(), "UNAUTHENTICATED", "identity headers without the secret are ignored")
+
+	moderator["X-Gateway-Secret"] = "wrong"
+	rec = serve(r, http.MethodPost, "/graphql", queue, moderator)
+	assert.Contains(t, rec.Body.String(), "UNAUTHENTICATED")
+
+	moderator["X-Gateway-Secret"] = "s3cret"
+	rec = serve(r, http.MethodPost, "/graphql", queue, moderator)
+	assert.JSONEq(t, `{"data":{"moderationQueue":{"hasNextPage":false}}}`, rec.Body.String())
+}
+
+func TestPersistedQueries(t *testing.T) {
+	r := testRouter(t)
+	const query = "{ posts { id } }"
+	hash := persisted.Hash(query)
+	ext := `"extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + hash + `"}}`
+
+	rec := serve(r, http.MethodPost, "/graphql", `{`+ext+`}`, nil)
+	assert.Contains(t, rec.Body.String(), "PERSISTED_QUERY_NOT_FOUND")
+
+	rec = serve(r, http.MethodPost, "/graphql", `{"query":"`+query+`",`+ext+`}`, nil)
+	assert.JSONEq(t, `{"data":{"posts":[]}}`, rec.Body.String())
+
+	rec = serve(r, http.MethodPost, "/graphql", `{`+ext+`}`, nil)
+	assert.JSONEq(t, `{"data":{"posts":[]}}`, rec.Body.String())
+}
+
+func TestPersistedQueries_Manifest(t *testing.T) {
+	body := "query Posts {\n  posts {\n    id\n  }\n}\n"
+	id := persisted.Hash(body)
+	data, err := json.Marshal(persisted.Manifest{Format: persisted.ManifestFormat, Version: 1, Operations: []persisted.Operation{
+		{ID: id, Name: "Posts", Type: "query", Body: body},
+	}})
+	require.NoError(t, err)
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	require.NoError(t, os.WriteFile(manifest, data, 0o644))
+	byID := `{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + id + `"}}}`
+
+	// A registered query does not push the manifest out of a full cache.
+	r := testRouter(t, "-persisted-queries-manifest", manifest, "-apq-cache-size", "1")
+	other := `{ __typename }`
+	rec := serve(r, http.MethodPost, "/graphql", `{"query":"`+other+`","extensions":{"persistedQuery":{"version":1,"sha256Hash":"`+persisted.Hash(other)+`"}}}`, nil)
+	require.JSONEq(t, `{"data":{"__typename":"Query"}}`, rec.Body.String())
+	rec = serve(r, http.MethodPost, "/graphql", byID, nil)
+	assert.JSONEq(t, `{"data":{"posts":[]}}`, rec.Body.String())
+
+	strict := testRouter(t, "-persisted-queries-manifest", manifest, "-persisted-queries-strict")
+	rec = serve(strict, http.MethodPost, "/graphql", byID, nil)
+	assert.JSONEq(t, `{"data":{"posts":[]}}`, rec.Body.String())
+	rec = serve(strict, http.MethodPost, "/graphql", `{"query":"query Posts { posts { id } }"}`, nil)
+	assert.JSONEq(t, `{"data":{"posts":[]}}`, rec.Body.String(), "the client's formatting is accepted")
+	rec = serve(strict, http.MethodPost, "/graphql", `{"query":"{ posts { textPost } }"}`, nil)
+	assert.Contains(t, rec.Body.String(), persisted.ErrNotAllowed)
+}
+
+func TestCORS(t *testing.T) {
+	r := testRouter(t, "-cors-allowed-origins", "https://app.example,https://admin.example")
+	preflight := map[string]string{
+		"Origin":                         "https://app.example",
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type",
+	}
+
+	rec := serve(r, http.MethodOptions, "/graphql", "", preflight)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://app.example", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), "POST")
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Content-Type")
+
+	preflight["Origin"] = "https://evil.example"
+	rec = serve(r, http.MethodOptions, "/graphql", "", preflight)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	rec = serve(r, http.MethodPost, "/graphql", `{"query":"{ posts { id } }"}`, map[string]string{"Origin": "https://admin.example"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://admin.example", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Values("Vary"), "Origin")
+}
+
+func TestRESTAPI(t *testing.T) {
+	r := testRouter(t)
+	rec := serve(r, http.MethodPost, "/api/v1/posts", `{"text":"over REST","author":"ann","commentable":true}`, map[string]string{"X-User-ID": "ann"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = serve(r, http.MethodPost, "/graphql", `{"query":"{ posts { textPost } }"}`, nil)
+	assert.JSONEq(t, `{"data":{"posts":[{"textPost":"over REST"}]}}`, rec.Body.String())
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, "/api/v1/openapi.yaml", "", nil).Code)
+}